@@ -0,0 +1,48 @@
+// Command fakecs serves the fake code search API for local development:
+//
+//	fakecs -addr localhost:8080 -corpus ./corpus &
+//	knollledge -auth=token -token=fake -cs-url http://localhost:8080/api/ ...
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"os"
+
+	"github.com/abergmeier/knollledge/internal/fake"
+)
+
+var (
+	addr      = flag.String("addr", "localhost:8080", "Address to listen on")
+	corpusDir = flag.String("corpus", "", "Directory of files laid out as owner/repo/path, the built-in corpus if empty")
+	pageSize  = flag.Int("page-size", fake.DefaultPageSize, "Results per page")
+	rateLimit = flag.Int("rate-limit", 0, "Requests allowed per hour, 0 for unlimited")
+)
+
+func main() {
+	flag.Parse()
+
+	corpus := fake.DefaultCorpus()
+	if *corpusDir != "" {
+		var err error
+		corpus, err = fake.CorpusFS(os.DirFS(*corpusDir))
+		if err != nil {
+			log.Fatalf("Loading corpus failed: %s\n", err)
+		}
+	}
+
+	l, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Fatalf("Listening on %s failed: %s\n", *addr, err)
+	}
+
+	srv := fake.NewUnstartedServer(corpus)
+	srv.Listener.Close()
+	srv.Listener = l
+	srv.PageSize = *pageSize
+	srv.RateLimit = *rateLimit
+	srv.Start()
+	log.Printf("Serving %d files at %s\n", len(corpus), srv.CodeSearchURL())
+	select {}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/abergmeier/knollledge/internal/lint"
+)
+
+// lintFiles checks the job files and writes their problems to w. It
+// returns the number of problems.
+func lintFiles(w io.Writer, files []string) (int, error) {
+	l := lint.New()
+	n := 0
+	for _, f := range files {
+		problems, err := l.File(f)
+		if err != nil {
+			return n, err
+		}
+		for _, p := range problems {
+			fmt.Fprintln(w, p)
+		}
+		n += len(problems)
+	}
+	return n, nil
+}
@@ -3,30 +3,106 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/abergmeier/knollledge/internal/config"
+	"github.com/abergmeier/knollledge/internal/github"
 	"github.com/abergmeier/knollledge/internal/job"
+	"github.com/abergmeier/knollledge/internal/rest"
+	"github.com/abergmeier/knollledge/internal/search"
 )
 
 var (
 	inDir  = flag.String("in-dir", "", "")
 	outDir = flag.String("out-dir", "", "")
+
+	presetFile = flag.String("presets", config.DefaultPresetFile(), "YAML file defining additional presets")
+
+	backend = flag.String("backend", "cs", "Search backend: cs for cs.github.com or rest for the REST API /search/code")
+	csURL   = flag.String("cs-url", "", "Base URL of the code search API for -backend=cs, e.g. of a fakecs server")
+
+	concurrency = flag.Int("jobs", 4, "Number of jobs to run concurrently")
+	limiter     = &github.Limiter{}
+
+	cacheResponses = flag.Bool("cache", false, "Cache responses of -backend=cs in -cache-dir")
+	cache          = &github.Cache{}
+
+	auth = config.Auth{}
 )
 
+func init() {
+	flag.StringVar(&auth.Method, "auth", config.AuthCookie, "How to authenticate: cookie, token or app")
+	auth.CookieFiles = []string{config.DefaultCookieFile()}
+	flag.Var(&listFlag{values: &auth.CookieFiles}, "cookie-file", "Session cookies for -auth=cookie, repeat for a pool of sessions")
+	flag.Var(&listFlag{values: &auth.Tokens}, "token", "Personal access token for -auth=token, repeat for a pool of tokens. Defaults to $GITHUB_TOKEN")
+	// Set after registering the flag to keep the token out of -help.
+	if t := os.Getenv("GITHUB_TOKEN"); t != "" {
+		auth.Tokens = []string{t}
+	}
+	flag.Int64Var(&auth.AppID, "app-id", 0, "GitHub App id for -auth=app")
+	flag.Int64Var(&auth.InstallationID, "app-installation-id", 0, "GitHub App installation id for -auth=app")
+	flag.StringVar(&auth.PrivateKeyFile, "app-private-key", "", "GitHub App private key file for -auth=app")
+
+	flag.Float64Var(&limiter.Rate, "rate", 1, "Requests per second across all jobs, 0 for no limit")
+	flag.IntVar(&limiter.Burst, "burst", 4, "Requests which may be sent at once before -rate applies")
+	flag.IntVar(&limiter.MaxInFlight, "max-in-flight", 2, "Requests which may be outstanding at the same time, 0 for no limit")
+	flag.IntVar(&limiter.Budget, "budget", 0, "Requests the run may send, retries included, 0 for no limit")
+
+	flag.StringVar(&cache.Dir, "cache-dir", config.DefaultCacheDir(), "Directory responses are cached in")
+	flag.DurationVar(&cache.TTL, "cache-ttl", time.Hour, "How long cached responses are served without asking the server")
+	flag.BoolVar(&cache.Offline, "offline", false, "Serve all responses from the cache and fail jobs whose responses are not cached. Implies -cache")
+}
+
 func main() {
+	flag.Parse()
 
-	gp := filepath.Join(*inDir, "*.json")
-	matches, err := filepath.Glob(gp)
-	log.Printf("Globbing %s\n", gp)
+	if err := config.LoadPresets(*presetFile); err != nil {
+		log.Fatalf("Loading presets failed: %s\n", err)
+	}
 
-	if err != nil {
-		panic(err)
+	switch flag.Arg(0) {
+	case "":
+	case "presets":
+		if err := presets(os.Stdout, flag.Args()[1:]); err != nil {
+			log.Fatalf("%s\n", err)
+		}
+		return
+	case "lint":
+		files := flag.Args()[1:]
+		if len(files) == 0 {
+			files = jobFiles()
+		}
+		mustLint(files)
+		return
+	default:
+		log.Fatalf("Unknown command %q\n", flag.Arg(0))
+	}
+
+	// Offline runs send no requests and need no credentials.
+	creds := []github.Credential{}
+	if !cache.Offline {
+		var err error
+		creds, err = auth.Credentials()
+		if err != nil {
+			log.Fatalf("Setting up %s authentication failed: %s\n", auth.Method, err)
+		}
 	}
+	pool := github.NewPool(creds...)
+	s := newSearcher(pool)
+
+	matches := jobFiles()
+	// Bad job files would only fail after spending rate limit.
+	mustLint(matches)
 
 	ctx := context.Background()
 
@@ -48,11 +124,82 @@ func main() {
 		}(m)
 	}
 
-	for cs := range css {
-		h := cs.Hash()
-		op := filepath.Join(*outDir, fmt.Sprintf(h, ".json"))
-		mustRun(ctx, cs, op)
+	manifest, err := job.LoadManifest(*outDir)
+	if err != nil {
+		log.Fatalf("Loading manifest failed: %s\n", err)
+	}
+
+	r := &runner{
+		searcher: s,
+		limiter:  limiter,
+		outDir:   *outDir,
+		manifest: manifest,
+	}
+	jobs := make(chan *job.CodeSearch)
+	go func() {
+		defer close(jobs)
+		for cs := range css {
+			// Expanding depends on the limits of the backend.
+			cs.Searcher = s
+			expanded, err := expand(cs)
+			if err != nil {
+				log.Printf("Skipping job: %s\n", err)
+				r.fail()
+				continue
+			}
+			for _, cs := range expanded {
+				jobs <- cs
+			}
+		}
+	}()
+	r.run(ctx, jobs, *concurrency)
+
+	r.report()
+	for _, u := range pool.Usage() {
+		log.Printf("Credential %s\n", u)
+	}
+	// Tell how to fix what aborted the run.
+	if errors.Is(r.fatal, github.ErrSessionExpired) {
+		log.Fatalf("%s\n%s\n", r.fatal, sessionExpiredHint())
+	}
+	if r.fatal != nil {
+		log.Fatalf("%s\n", r.fatal)
+	}
+	if r.failed != 0 {
+		log.Fatalf("%d jobs failed\n", r.failed)
+	}
+}
+
+// jobFiles returns the job files in -in-dir.
+func jobFiles() []string {
+	gp := filepath.Join(*inDir, "*.json")
+	matches, err := filepath.Glob(gp)
+	log.Printf("Globbing %s\n", gp)
+
+	if err != nil {
+		panic(err)
+	}
+	return matches
+}
+
+// mustLint exits if the job files have problems.
+func mustLint(files []string) {
+	n, err := lintFiles(os.Stdout, files)
+	if err != nil {
+		log.Fatalf("Linting failed: %s\n", err)
+	}
+	if n != 0 {
+		log.Fatalf("%d problems in job files\n", n)
+	}
+}
+
+// expand resolves the preset of cs and expands its scope.
+func expand(cs *job.CodeSearch) ([]*job.CodeSearch, error) {
+	err := config.Resolve(cs)
+	if err != nil {
+		return nil, err
 	}
+	return cs.Expand()
 }
 
 func fileToChan(m string, css chan<- *job.CodeSearch) {
@@ -76,12 +223,102 @@ func fileToChan(m string, css chan<- *job.CodeSearch) {
 	}
 }
 
-func mustRun(ctx context.Context, cs *job.CodeSearch, file string) {
+// newSearcher returns a Searcher for -backend sending requests through
+// pool.
+func newSearcher(pool *github.Pool) search.Searcher {
+	switch *backend {
+	case "cs":
+		c := github.NewClient(&http.Client{Transport: pool})
+		c.Limiter = limiter
+		if *cacheResponses || cache.Offline {
+			c.Cache = cache
+		}
+		if *csURL != "" {
+			u, err := url.Parse(*csURL)
+			if err != nil {
+				log.Fatalf("Invalid -cs-url: %s\n", err)
+			}
+			c.CodeSearchURL = u
+		}
+		return c.CodeSearch().Searcher()
+	case "rest":
+		if *cacheResponses || cache.Offline {
+			log.Fatalf("-cache and -offline require -backend=cs\n")
+		}
+		return rest.NewClient(&http.Client{
+			Transport: &github.LimitTransport{
+				Limiter: limiter,
+				Base:    pool,
+			},
+		}).Searcher()
+	default:
+		log.Fatalf("Unknown backend %q\n", *backend)
+		return nil
+	}
+}
 
-	f, err := os.Create(file)
+// run runs cs and writes its result to file. Partial results of failed
+// runs are written and returned as well. Runs failing before they fetched
+// a page leave file as it is, so the result of a previous run is kept.
+func run(ctx context.Context, cs *job.CodeSearch, file string) (*job.Result, error) {
+	res, runErr := cs.Run(ctx)
+	if fetched(res) {
+		if err := writeResult(file, res); err != nil {
+			return res, err
+		}
+	}
+	return res, runErr
+}
+
+// fetched reports whether res holds at least one page.
+func fetched(res *job.Result) bool {
+	return res != nil && len(res.Pages) != 0
+}
+
+// writeResult writes res to a temporary file next to file and renames it
+// to file, so file never holds a partial result.
+func writeResult(file string, res *job.Result) error {
+	tmp, err := os.CreateTemp(filepath.Dir(file), filepath.Base(file)+".*")
 	if err != nil {
-		panic(err)
+		return err
 	}
-	defer f.Close()
-	job.MustRun(ctx, cs, f)
+	err = job.Write(tmp, res)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+func sessionExpiredHint() string {
+	if auth.Method != config.AuthCookie {
+		return fmt.Sprintf("cs.github.com only accepts browser sessions, run with -auth=%s or -backend=rest.", config.AuthCookie)
+	}
+	return fmt.Sprintf("Log in to github.com in your browser, then export its cookies as cookies.txt (or point -cookie-file at the browser's cookie database) and update %s.", strings.Join(auth.CookieFiles, ", "))
+}
+
+// listFlag is a flag which may be given several times. The values given
+// replace the default.
+type listFlag struct {
+	values *[]string
+	set    bool
+}
+
+func (f *listFlag) String() string {
+	if f.values == nil {
+		return ""
+	}
+	return strings.Join(*f.values, ", ")
+}
+
+func (f *listFlag) Set(v string) error {
+	if !f.set {
+		*f.values = nil
+		f.set = true
+	}
+	*f.values = append(*f.values, v)
+	return nil
 }
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/abergmeier/knollledge/internal/config"
+)
+
+// presets runs the presets command with args.
+func presets(w io.Writer, args []string) error {
+	if len(args) != 1 || args[0] != "list" {
+		return fmt.Errorf("usage: knollledge presets list")
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "NAME\tQUERY\tMAX PAGE\tTAGS\tSOURCE\n")
+	for _, p := range config.Presets() {
+		cs, err := config.ToCodeSearch(p.Name, "")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\n", p.Name, cs.Query, cs.MaxPageNumber, strings.Join(p.Tags, ","), p.Source)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"path/filepath"
+	"sync"
+
+	"github.com/abergmeier/knollledge/internal/github"
+	"github.com/abergmeier/knollledge/internal/job"
+	"github.com/abergmeier/knollledge/internal/search"
+)
+
+// runner runs jobs concurrently. All jobs search through the same
+// Searcher, so they share its Limiter.
+type runner struct {
+	searcher search.Searcher
+	limiter  *github.Limiter
+	outDir   string
+
+	mu       sync.Mutex
+	manifest *job.Manifest
+	failed   int
+	// missed counts the jobs which failed on responses missing from the
+	// cache of an offline run.
+	missed int
+	// notRun holds the jobs which did not fetch a page before the request
+	// budget ran out, stopped those which fetched some.
+	notRun  []*job.CodeSearch
+	stopped []*job.CodeSearch
+	// fatal is the error which aborted the run, aborted counts the jobs
+	// it kept from completing.
+	fatal   error
+	aborted int
+	cancel  context.CancelFunc
+}
+
+// run runs the jobs received from jobs with workers jobs at a time. An
+// error failing every job the same way aborts the run, the remaining
+// jobs are received but not run.
+func (r *runner) run(ctx context.Context, jobs <-chan *job.CodeSearch, workers int) {
+	if workers < 1 {
+		workers = 1
+	}
+	ctx, r.cancel = context.WithCancel(ctx)
+	defer r.cancel()
+
+	wg := sync.WaitGroup{}
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for cs := range jobs {
+				r.runJob(ctx, cs)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (r *runner) runJob(ctx context.Context, cs *job.CodeSearch) {
+	cs.Searcher = r.searcher
+	// Jobs received after the run was aborted or the budget ran out would
+	// only fail.
+	if ctx.Err() != nil {
+		r.mu.Lock()
+		r.aborted++
+		r.mu.Unlock()
+		return
+	}
+	if r.limiter.Exhausted() {
+		r.mu.Lock()
+		r.notRun = append(r.notRun, cs)
+		r.mu.Unlock()
+		return
+	}
+
+	cs.Checkpoints = job.CheckpointDir(r.outDir)
+	res, err := run(ctx, cs, filepath.Join(r.outDir, job.Output(cs)))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// Without a page fetched the output and its manifest entry are those
+	// of the previous run, if any.
+	if fetched(res) {
+		r.manifest.Add(cs, res)
+		if err := r.manifest.Save(r.outDir); err != nil {
+			log.Printf("Saving manifest failed: %s\n", err)
+		}
+	}
+	switch {
+	// An expired session or a pool without usable credentials fails
+	// every queued job the same way, so abort the run.
+	case errors.Is(err, github.ErrSessionExpired), errors.Is(err, github.ErrNoCredentials):
+		if r.fatal == nil {
+			r.fatal = err
+		}
+		r.aborted++
+		r.cancel()
+	// Jobs in flight when the run was aborted.
+	case r.fatal != nil && errors.Is(err, context.Canceled):
+		r.aborted++
+	case errors.Is(err, github.ErrBudgetExhausted):
+		if !fetched(res) {
+			r.notRun = append(r.notRun, cs)
+		} else {
+			r.stopped = append(r.stopped, cs)
+		}
+	case errors.Is(err, github.ErrCacheMiss):
+		log.Printf("%s\n", err)
+		r.failed++
+		r.missed++
+	case err != nil:
+		log.Printf("%s\n", err)
+		r.failed++
+	}
+}
+
+// fail counts a job which failed before it could run.
+func (r *runner) fail() {
+	r.mu.Lock()
+	r.failed++
+	r.mu.Unlock()
+}
+
+// report logs the jobs the request budget, the cache or an aborted run
+// kept from completing.
+func (r *runner) report() {
+	if r.aborted != 0 {
+		log.Printf("%d jobs did not complete because the run was aborted\n", r.aborted)
+	}
+	if r.missed != 0 {
+		log.Printf("%d jobs need responses which are not cached, run them without -offline\n", r.missed)
+	}
+	if len(r.notRun) == 0 && len(r.stopped) == 0 {
+		return
+	}
+
+	log.Printf("Request budget of %d requests used up\n", r.limiter.Budget)
+	if len(r.stopped) != 0 {
+		log.Printf("%d jobs stopped early and resume with the next run:\n", len(r.stopped))
+		for _, cs := range r.stopped {
+			log.Printf("  %s %s\n", cs.Fingerprint(), describe(cs))
+		}
+	}
+	if len(r.notRun) != 0 {
+		log.Printf("%d jobs did not run:\n", len(r.notRun))
+		for _, cs := range r.notRun {
+			log.Printf("  %s %s\n", cs.Fingerprint(), describe(cs))
+		}
+	}
+}
+
+// describe returns the label of cs, or its query if it has none.
+func describe(cs *job.CodeSearch) string {
+	if cs.Label != "" {
+		return cs.Label
+	}
+	return cs.Query
+}
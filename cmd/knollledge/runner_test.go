@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/abergmeier/knollledge/internal/fake"
+	"github.com/abergmeier/knollledge/internal/github"
+	"github.com/abergmeier/knollledge/internal/job"
+)
+
+// newTestRunner returns a runner searching the code search API at
+// csURL, writing to a temporary directory.
+func newTestRunner(t *testing.T, csURL string, limiter *github.Limiter) *runner {
+	c := github.NewClient(nil)
+	c.CodeSearchURL, _ = url.Parse(csURL)
+	c.Limiter = limiter
+
+	dir := t.TempDir()
+	manifest, err := job.LoadManifest(dir)
+	if err != nil {
+		t.Fatal("Loading manifest failed:", err)
+	}
+	return &runner{
+		searcher: c.CodeSearch().Searcher(),
+		limiter:  limiter,
+		outDir:   dir,
+		manifest: manifest,
+	}
+}
+
+// queue returns a closed channel holding jobs.
+func queue(jobs ...*job.CodeSearch) <-chan *job.CodeSearch {
+	c := make(chan *job.CodeSearch, len(jobs))
+	for _, cs := range jobs {
+		c <- cs
+	}
+	close(c)
+	return c
+}
+
+func TestRunnerBudget(t *testing.T) {
+	srv := fake.NewServer(fake.DefaultCorpus())
+	defer srv.Close()
+	srv.PageSize = 2
+
+	r := newTestRunner(t, srv.CodeSearchURL(), &github.Limiter{Budget: 2})
+	java := &job.CodeSearch{Query: "path:**.java class", MaxPageNumber: 5}
+	gomod := &job.CodeSearch{Query: "path:**/go.mod", Label: "go modules"}
+	r.run(context.TODO(), queue(java, gomod), 1)
+
+	if r.failed != 0 || r.fatal != nil {
+		t.Fatalf("Unexpected failures: %d, %v", r.failed, r.fatal)
+	}
+	if len(r.stopped) != 1 || r.stopped[0] != java {
+		t.Errorf("Expected the java job to stop early, got %v", r.stopped)
+	}
+	if len(r.notRun) != 1 || r.notRun[0] != gomod {
+		t.Errorf("Expected the go.mod job not to run, got %v", r.notRun)
+	}
+	if n := len(srv.Queries()); n != 2 {
+		t.Errorf("Expected the budget of 2 requests to be kept, sent %d", n)
+	}
+
+	buf := bytes.Buffer{}
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	r.report()
+	for _, line := range []string{
+		"Request budget of 2 requests used up",
+		"1 jobs stopped early and resume with the next run:",
+		"  " + java.Fingerprint() + " path:**.java class",
+		"1 jobs did not run:",
+		"  " + gomod.Fingerprint() + " go modules",
+	} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Errorf("Report misses %q:\n%s", line, buf.String())
+		}
+	}
+}
+
+func TestRunnerSessionExpired(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte("<html>Sign in to GitHub</html>"))
+	}))
+	defer srv.Close()
+
+	r := newTestRunner(t, srv.URL+"/api/", &github.Limiter{})
+	java := &job.CodeSearch{Query: "path:**.java class"}
+	// The output of a previous run.
+	output := filepath.Join(r.outDir, job.Output(java))
+	if err := os.WriteFile(output, []byte("previous"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r.run(context.TODO(), queue(
+		java,
+		&job.CodeSearch{Query: "path:**/go.mod"},
+		&job.CodeSearch{Query: "path:**/Cargo.toml"},
+	), 1)
+
+	if !errors.Is(r.fatal, github.ErrSessionExpired) {
+		t.Fatal("Expected run to be aborted by the expired session, got", r.fatal)
+	}
+	if r.aborted != 3 || r.failed != 0 {
+		t.Errorf("Expected all 3 jobs to be aborted, %d aborted, %d failed", r.aborted, r.failed)
+	}
+	if data, err := os.ReadFile(output); err != nil || string(data) != "previous" {
+		t.Errorf("Expected output of the previous run to be kept, got %q, %v", data, err)
+	}
+	if len(r.manifest.Jobs) != 0 {
+		t.Errorf("Expected jobs failing before their first page to stay out of the manifest, got %v", r.manifest.Fingerprints())
+	}
+}
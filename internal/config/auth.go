@@ -0,0 +1,111 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/abergmeier/knollledge/internal/cookie"
+	"github.com/abergmeier/knollledge/internal/github"
+	"github.com/adrg/xdg"
+)
+
+const (
+	AuthCookie = "cookie"
+	AuthToken  = "token"
+	AuthApp    = "app"
+)
+
+// Auth selects and configures how the code search client authenticates.
+type Auth struct {
+	// Method is one of AuthCookie, AuthToken or AuthApp.
+	Method string
+
+	// CookieFiles hold the session cookies for AuthCookie, in any format
+	// cookie.Load understands, one session per file. Defaults to
+	// DefaultCookieFile.
+	CookieFiles []string
+
+	// Tokens are the personal access tokens for AuthToken.
+	Tokens []string
+
+	// AppID, InstallationID and PrivateKeyFile configure AuthApp.
+	AppID          int64
+	InstallationID int64
+	PrivateKeyFile string
+}
+
+// DefaultCookieFile returns where session cookies are looked up if no
+// cookie file is configured.
+func DefaultCookieFile() string {
+	return filepath.Join(xdg.ConfigHome, "knollledge/cookie.combined.txt")
+}
+
+// Credentials creates the credentials configured by a. Requests are
+// spread over them by a github.Pool.
+func (a *Auth) Credentials() ([]github.Credential, error) {
+	switch a.Method {
+	case AuthCookie, "":
+		files := a.CookieFiles
+		if len(files) == 0 {
+			files = []string{DefaultCookieFile()}
+		}
+		creds := []github.Credential{}
+		for _, p := range files {
+			cookies, err := cookie.Load(p)
+			if err != nil {
+				return nil, err
+			}
+			if len(cookies) == 0 {
+				return nil, fmt.Errorf("%s contains no valid cookies", p)
+			}
+			creds = append(creds, github.Credential{
+				Name:          p,
+				Authenticator: &github.CookieAuthenticator{Cookies: cookies},
+			})
+		}
+		return creds, nil
+	case AuthToken:
+		if len(a.Tokens) == 0 {
+			return nil, fmt.Errorf("auth method %q requires a token", a.Method)
+		}
+		creds := []github.Credential{}
+		for _, t := range a.Tokens {
+			if t == "" {
+				return nil, fmt.Errorf("auth method %q requires non-empty tokens", a.Method)
+			}
+			creds = append(creds, github.Credential{
+				Name:          tokenName(t),
+				Authenticator: &github.TokenAuthenticator{Token: t},
+			})
+		}
+		return creds, nil
+	case AuthApp:
+		if a.AppID == 0 || a.InstallationID == 0 || a.PrivateKeyFile == "" {
+			return nil, fmt.Errorf("auth method %q requires an app id, installation id and private key file", a.Method)
+		}
+		key, err := os.ReadFile(a.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		app, err := github.NewAppAuthenticator(a.AppID, a.InstallationID, key)
+		if err != nil {
+			return nil, err
+		}
+		return []github.Credential{{
+			Name:          fmt.Sprintf("app %d installation %d", a.AppID, a.InstallationID),
+			Authenticator: app,
+		}}, nil
+	default:
+		return nil, fmt.Errorf("unknown auth method %q", a.Method)
+	}
+}
+
+// tokenName names the token t by its last characters, like GitHub lists
+// tokens.
+func tokenName(t string) string {
+	if len(t) <= 8 {
+		return "token"
+	}
+	return "token ..." + t[len(t)-4:]
+}
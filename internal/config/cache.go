@@ -0,0 +1,13 @@
+package config
+
+import (
+	"path/filepath"
+
+	"github.com/adrg/xdg"
+)
+
+// DefaultCacheDir returns where responses are cached if no cache
+// directory is configured.
+func DefaultCacheDir() string {
+	return filepath.Join(xdg.CacheHome, "knollledge/http")
+}
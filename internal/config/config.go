@@ -1,18 +1,82 @@
 package config
 
-import "github.com/abergmeier/knollledge/internal/job"
+import (
+	"fmt"
+	"sort"
+
+	"github.com/abergmeier/knollledge/internal/job"
+)
+
+// SourceBuiltin is the Source of the predefined presets.
+const SourceBuiltin = "built-in"
+
+// PresetInfo describes a registered preset.
+type PresetInfo struct {
+	Name string
+	Tags []string
+	// Source is SourceBuiltin or the file the preset was loaded from.
+	Source string
+
+	make job.MakeCodeSearchFunc
+}
 
 var (
-	codeSearches = make(map[string]job.MakeCodeSearchFunc, len(predefinedCodeSearches))
+	codeSearches = make(map[string]*PresetInfo, len(predefinedCodeSearches))
 )
 
 func init() {
-	for k, cs := range codeSearches {
-		codeSearches[k] = cs
+	for k, cs := range predefinedCodeSearches {
+		codeSearches[k] = &PresetInfo{
+			Name:   k,
+			Source: SourceBuiltin,
+			make:   cs,
+		}
+	}
+}
+
+// Presets returns all registered presets, sorted by name.
+func Presets() []*PresetInfo {
+	presets := make([]*PresetInfo, 0, len(codeSearches))
+	for _, p := range codeSearches {
+		presets = append(presets, p)
 	}
+	sort.Slice(presets, func(i, j int) bool {
+		return presets[i].Name < presets[j].Name
+	})
+	return presets
 }
 
-func mustToCodeSearch(name string, queryPrefix string) job.CodeSearch {
-	csf := codeSearches[name]
-	return csf(queryPrefix)
+// ToCodeSearch expands the preset name, prefixing its query with
+// queryPrefix.
+func ToCodeSearch(name string, queryPrefix string) (job.CodeSearch, error) {
+	p, ok := codeSearches[name]
+	if !ok {
+		return job.CodeSearch{}, fmt.Errorf("unknown preset %q", name)
+	}
+	return p.make(queryPrefix)
+}
+
+// Resolve expands the preset cs references, if any, into its query. Jobs
+// either reference a preset or carry a query, not both. A job without
+// max_page takes the one of its preset.
+func Resolve(cs *job.CodeSearch) error {
+	if cs.Preset == "" {
+		if cs.QueryPrefix != "" {
+			return fmt.Errorf("query_prefix %q without preset", cs.QueryPrefix)
+		}
+		return nil
+	}
+	if cs.Query != "" {
+		return fmt.Errorf("job has both preset %q and query %q", cs.Preset, cs.Query)
+	}
+
+	p, err := ToCodeSearch(cs.Preset, cs.QueryPrefix)
+	if err != nil {
+		return err
+	}
+	cs.Query = p.Query
+	if cs.MaxPageNumber == 0 {
+		cs.MaxPageNumber = p.MaxPageNumber
+	}
+	return nil
 }
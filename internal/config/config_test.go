@@ -0,0 +1,62 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/abergmeier/knollledge/internal/job"
+)
+
+func TestPresetsRegistered(t *testing.T) {
+	if len(Presets()) != len(predefinedCodeSearches) {
+		t.Fatalf("Registered %d of %d presets", len(Presets()), len(predefinedCodeSearches))
+	}
+	for _, p := range Presets() {
+		name := p.Name
+		cs, err := ToCodeSearch(name, "")
+		if err != nil {
+			t.Errorf("Expanding %s failed: %s", name, err)
+		}
+		if cs.Query == "" {
+			t.Errorf("Preset %s has an empty query", name)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	cs := &job.CodeSearch{
+		Preset:        "go-modules",
+		QueryPrefix:   "org:abergmeier ",
+		MaxPageNumber: 2,
+	}
+	err := Resolve(cs)
+	if err != nil {
+		t.Fatal("Resolve failed:", err)
+	}
+	if cs.Query != "org:abergmeier path:**/go.mod" || cs.MaxPageNumber != 2 {
+		t.Errorf("Unexpected job: %+v", cs)
+	}
+
+	cs = &job.CodeSearch{Preset: "go-modules", QueryPrefix: "org:abergmeier"}
+	Resolve(cs)
+	if cs.Query != "org:abergmeier path:**/go.mod" {
+		t.Error("Query prefix not separated:", cs.Query)
+	}
+
+	cs = &job.CodeSearch{Preset: "bazel-package", QueryPrefix: "org:abergmeier"}
+	Resolve(cs)
+	if cs.Query != "org:abergmeier (path:**/BUILD OR path:**/BUILD.bazel)" {
+		t.Error("Query prefix does not restrict all alternatives:", cs.Query)
+	}
+
+	invalid := []*job.CodeSearch{
+		{Preset: "no-such-preset"},
+		{Preset: "go-modules", Query: "path:**/go.mod"},
+		{Query: "path:**/go.mod", QueryPrefix: "org:abergmeier "},
+		{Preset: "go-modules", QueryPrefix: "(org:abergmeier OR org:acme"},
+	}
+	for _, cs := range invalid {
+		if err := Resolve(cs); err == nil {
+			t.Errorf("Expected %+v to fail", cs)
+		}
+	}
+}
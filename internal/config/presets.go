@@ -0,0 +1,124 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/abergmeier/knollledge/internal/job"
+	"github.com/adrg/xdg"
+	"gopkg.in/yaml.v3"
+)
+
+// Preset is a named query defined in a preset file:
+//
+//	presets:
+//	  helm-chart:
+//	    query: path:**/Chart.yaml
+//	    max_page: 10
+//	    tags: [kubernetes]
+//	  go-modules:
+//	    query: path:**/go.mod require
+//	    override: true
+type Preset struct {
+	Query string `yaml:"query"`
+	// MaxPageNumber applies to jobs not setting max_page themselves.
+	MaxPageNumber int      `yaml:"max_page"`
+	Tags          []string `yaml:"tags"`
+	// Override has to be set to replace a built-in preset of the same
+	// name.
+	Override bool `yaml:"override"`
+}
+
+type presetFile struct {
+	Presets map[string]*Preset `yaml:"presets"`
+}
+
+var presetName = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// DefaultPresetFile returns where user presets are looked up if no preset
+// file is configured.
+func DefaultPresetFile() string {
+	return filepath.Join(xdg.ConfigHome, "knollledge/presets.yaml")
+}
+
+// LoadPresets reads the presets of path and registers them next to the
+// built-in ones. A missing file defines no presets.
+func LoadPresets(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	f := presetFile{}
+	err = yaml.Unmarshal(data, &f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return registerPresets(path, f.Presets)
+}
+
+// registerPresets validates all presets before registering any of them,
+// so a broken file leaves the registered presets untouched.
+func registerPresets(source string, presets map[string]*Preset) error {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	msgs := []string{}
+	makes := map[string]job.MakeCodeSearchFunc{}
+	for _, name := range names {
+		mk, err := validatePreset(name, presets[name])
+		if err != nil {
+			msgs = append(msgs, err.Error())
+		}
+		makes[name] = mk
+	}
+	if len(msgs) != 0 {
+		return fmt.Errorf("%s: %s", source, strings.Join(msgs, "; "))
+	}
+
+	for _, name := range names {
+		p := presets[name]
+		codeSearches[name] = &PresetInfo{
+			Name:   name,
+			Tags:   p.Tags,
+			Source: source,
+			make:   makes[name],
+		}
+	}
+	return nil
+}
+
+// validatePreset returns the MakeCodeSearchFunc of the preset p named
+// name if p is valid.
+func validatePreset(name string, p *Preset) (job.MakeCodeSearchFunc, error) {
+	if !presetName.MatchString(name) {
+		return nil, fmt.Errorf("preset %q: name must be lowercase words separated by -", name)
+	}
+	if p == nil || strings.TrimSpace(p.Query) == "" {
+		return nil, fmt.Errorf("preset %q: query is empty", name)
+	}
+	if p.MaxPageNumber < 0 {
+		return nil, fmt.Errorf("preset %q: max_page is negative", name)
+	}
+	existing, ok := codeSearches[name]
+	if ok && !p.Override {
+		return nil, fmt.Errorf("preset %q conflicts with the one from %s, set override to replace it", name, existing.Source)
+	}
+	mk, err := job.MakeQueryCodeSearch(p.Query, p.MaxPageNumber)
+	if err != nil {
+		return nil, fmt.Errorf("preset %q: %w", name, err)
+	}
+	return mk, nil
+}
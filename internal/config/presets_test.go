@@ -0,0 +1,84 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/abergmeier/knollledge/internal/job"
+	"github.com/google/go-cmp/cmp"
+)
+
+// restorePresets resets the registered presets when the test finishes.
+func restorePresets(t *testing.T) {
+	saved := make(map[string]*PresetInfo, len(codeSearches))
+	for k, p := range codeSearches {
+		saved[k] = p
+	}
+	t.Cleanup(func() {
+		codeSearches = saved
+	})
+}
+
+func TestLoadPresets(t *testing.T) {
+	restorePresets(t)
+
+	err := LoadPresets("testdata/presets.yaml")
+	if err != nil {
+		t.Fatal("LoadPresets failed:", err)
+	}
+
+	cs := &job.CodeSearch{
+		Preset:      "helm-chart",
+		QueryPrefix: "org:abergmeier ",
+	}
+	err = Resolve(cs)
+	if err != nil {
+		t.Fatal("Resolve failed:", err)
+	}
+	if cs.Query != "org:abergmeier path:**/Chart.yaml" || cs.MaxPageNumber != 10 {
+		t.Errorf("Unexpected job: %+v", cs)
+	}
+
+	gomod, err := ToCodeSearch("go-modules", "")
+	if err != nil || gomod.Query != "path:**/go.mod require" {
+		t.Errorf("Built-in preset not overridden: %+v %v", gomod, err)
+	}
+
+	sources := map[string]string{}
+	for _, p := range Presets() {
+		sources[p.Name] = p.Source
+	}
+	if sources["go-modules"] != "testdata/presets.yaml" || sources["bazel-package"] != SourceBuiltin {
+		t.Error("Unexpected sources:", sources)
+	}
+	if diff := cmp.Diff(codeSearches["helm-chart"].Tags, []string{"kubernetes", "helm"}); diff != "" {
+		t.Errorf("Unexpected tags:\n%s\n", diff)
+	}
+}
+
+func TestLoadPresetsInvalid(t *testing.T) {
+	restorePresets(t)
+	n := len(codeSearches)
+
+	err := LoadPresets("testdata/invalid.yaml")
+	if err == nil {
+		t.Fatal("Expected invalid presets to fail")
+	}
+	for _, name := range []string{"Helm_Chart", "empty-query", "go-modules", "unbalanced"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("Error does not mention %s: %s", name, err)
+		}
+	}
+	if len(codeSearches) != n {
+		t.Error("Presets of invalid file registered")
+	}
+}
+
+func TestLoadPresetsMissing(t *testing.T) {
+	restorePresets(t)
+
+	err := LoadPresets("testdata/missing.yaml")
+	if err != nil {
+		t.Fatal("Missing preset file failed:", err)
+	}
+}
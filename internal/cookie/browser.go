@@ -0,0 +1,197 @@
+package cookie
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// chromiumEpochOffset is the number of seconds between the origin of
+// Chromium's expires_utc timestamps, 1601-01-01, and the Unix epoch.
+const chromiumEpochOffset = 11644473600
+
+// Chromium on Linux encrypts cookie values with this password when no
+// keyring is available. Values encrypted with a keyring secret ("v11"),
+// the macOS Keychain or Windows DPAPI cannot be read, export them to a
+// cookies.txt instead.
+const chromiumDefaultPassword = "peanuts"
+
+// unsupportedEncryption returns the error for cookie values encrypted as
+// described by scheme.
+func unsupportedEncryption(scheme string) error {
+	return fmt.Errorf("cookie value encrypted with %s, which is not supported: only Chromium on Linux without a keyring (v10) is, export the cookies to a cookies.txt instead", scheme)
+}
+
+// loadBrowserDB reads the cookie database of Firefox (moz_cookies) or
+// Chromium (cookies).
+func loadBrowserDB(path string) ([]*http.Cookie, error) {
+	db, err := openSQLite(path)
+	if err != nil {
+		return nil, err
+	}
+	return loadDB(db)
+}
+
+func loadDB(db *sqliteDB) ([]*http.Cookie, error) {
+	if t, err := db.table("moz_cookies"); err == nil {
+		return loadFirefox(db, t)
+	}
+	t, err := db.table("cookies")
+	if err != nil {
+		return nil, errors.New("neither a Firefox nor a Chromium cookie database")
+	}
+	return loadChromium(db, t, chromiumDefaultPassword)
+}
+
+func loadFirefox(db *sqliteDB, t *sqliteTable) ([]*http.Cookie, error) {
+	cookies := []*http.Cookie{}
+	err := db.rows(t, func(row map[string]interface{}) error {
+		c := &http.Cookie{
+			Name:     str(row["name"]),
+			Value:    str(row["value"]),
+			Domain:   str(row["host"]),
+			Path:     str(row["path"]),
+			Secure:   integer(row["isSecure"]) != 0,
+			HttpOnly: integer(row["isHttpOnly"]) != 0,
+		}
+		if exp := integer(row["expiry"]); exp != 0 {
+			// Recent Firefox versions store milliseconds.
+			if exp > 1e12 {
+				c.Expires = time.UnixMilli(exp)
+			} else {
+				c.Expires = time.Unix(exp, 0)
+			}
+		}
+		cookies = append(cookies, c)
+		return nil
+	})
+	return cookies, err
+}
+
+func loadChromium(db *sqliteDB, t *sqliteTable, password string) ([]*http.Cookie, error) {
+	version := 0
+	if meta, err := db.table("meta"); err == nil {
+		db.rows(meta, func(row map[string]interface{}) error {
+			if str(row["key"]) == "version" {
+				version, _ = strconv.Atoi(str(row["value"]))
+			}
+			return nil
+		})
+	}
+
+	key := chromiumKey(password)
+	cookies := []*http.Cookie{}
+	err := db.rows(t, func(row map[string]interface{}) error {
+		c := &http.Cookie{
+			Name:     str(row["name"]),
+			Value:    str(row["value"]),
+			Domain:   str(row["host_key"]),
+			Path:     str(row["path"]),
+			Secure:   integer(row["is_secure"]) != 0,
+			HttpOnly: integer(row["is_httponly"]) != 0,
+		}
+		if exp := integer(row["expires_utc"]); exp != 0 {
+			c.Expires = time.Unix(exp/1e6-chromiumEpochOffset, exp%1e6*1e3)
+		}
+
+		if enc, _ := row["encrypted_value"].([]byte); c.Value == "" && len(enc) != 0 {
+			v, err := decryptChromium(key, enc, c.Domain, version)
+			// Without it, the database is of no use.
+			if err != nil && c.Name == SessionCookie {
+				return fmt.Errorf("%s cookie for %s: %w", c.Name, c.Domain, err)
+			}
+			if err != nil {
+				log.Printf("Warning: skipping cookie %s for %s: %s\n", c.Name, c.Domain, err)
+				return nil
+			}
+			c.Value = v
+		}
+		cookies = append(cookies, c)
+		return nil
+	})
+	return cookies, err
+}
+
+// chromiumKey derives the AES key from password with PBKDF2-HMAC-SHA1,
+// using Chromium's fixed salt and a single iteration.
+func chromiumKey(password string) []byte {
+	mac := hmac.New(sha1.New, []byte(password))
+	mac.Write([]byte("saltysalt"))
+	mac.Write([]byte{0, 0, 0, 1})
+	return mac.Sum(nil)[:16]
+}
+
+func decryptChromium(key, enc []byte, host string, version int) (string, error) {
+	switch {
+	case bytes.HasPrefix(enc, []byte("v11")):
+		return "", unsupportedEncryption("a keyring secret (v11)")
+	case !bytes.HasPrefix(enc, []byte("v10")):
+		return "", unsupportedEncryption("an unknown scheme")
+	}
+	enc = enc[3:]
+	// Chromium on Windows uses AES-GCM, which needs no padding.
+	if len(enc) == 0 || len(enc)%aes.BlockSize != 0 {
+		return "", unsupportedEncryption("an unknown key (v10 of Windows?)")
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	iv := bytes.Repeat([]byte{' '}, aes.BlockSize)
+	plain := make([]byte, len(enc))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, enc)
+
+	pad := int(plain[len(plain)-1])
+	if pad == 0 || pad > aes.BlockSize || pad > len(plain) {
+		return "", unsupportedEncryption("an unknown key (v10 of macOS?)")
+	}
+	plain = plain[:len(plain)-pad]
+
+	// Since database version 24 values are prefixed with the SHA256 of
+	// the host they belong to.
+	if version >= 24 && len(plain) >= sha256.Size {
+		sum := sha256.Sum256([]byte(host))
+		if !bytes.Equal(plain[:sha256.Size], sum[:]) {
+			return "", errors.New("host digest mismatch")
+		}
+		plain = plain[sha256.Size:]
+	}
+	return string(plain), nil
+}
+
+func str(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func integer(v interface{}) int64 {
+	switch v := v.(type) {
+	case int64:
+		return v
+	case float64:
+		return int64(v)
+	case string:
+		i, _ := strconv.ParseInt(v, 10, 64)
+		return i
+	default:
+		return 0
+	}
+}
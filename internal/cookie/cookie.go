@@ -0,0 +1,163 @@
+// Package cookie loads browser session cookies for authenticating
+// against cs.github.com.
+package cookie
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SessionCookie is the cookie carrying the GitHub login session.
+const SessionCookie = "user_session"
+
+const netscapeHeader = "# Netscape HTTP Cookie File"
+
+// Load reads cookies from path. It detects the format of the file, which
+// may be a Netscape cookies.txt, a Firefox cookies.sqlite, a Chromium
+// Cookies database or name=value pairs as copied from a Cookie header.
+// Expired cookies are dropped, a warning is logged if this affects
+// SessionCookie.
+func Load(path string) ([]*http.Cookie, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	head, _ := r.Peek(len(sqliteMagic))
+
+	var cookies []*http.Cookie
+	switch {
+	case isSQLite(head):
+		cookies, err = loadBrowserDB(path)
+	case looksLikeNetscape(r):
+		cookies, err = ParseNetscape(r)
+	default:
+		cookies, err = ParseHeader(r)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return dropExpired(path, cookies, time.Now()), nil
+}
+
+func dropExpired(path string, cookies []*http.Cookie, now time.Time) []*http.Cookie {
+	valid := cookies[:0]
+	for _, c := range cookies {
+		if !Expired(c, now) {
+			valid = append(valid, c)
+			continue
+		}
+		if c.Name == SessionCookie {
+			log.Printf("Warning: %s cookie for %s in %s expired at %s, log in again and re-export your cookies\n", c.Name, c.Domain, path, c.Expires.Format(time.RFC3339))
+		}
+	}
+	return valid
+}
+
+// Expired reports whether c has an expiry which lies before now. Session
+// cookies never expire.
+func Expired(c *http.Cookie, now time.Time) bool {
+	return !c.Expires.IsZero() && c.Expires.Before(now)
+}
+
+// looksLikeNetscape reports whether the buffered start of r is a
+// Netscape cookies.txt file.
+func looksLikeNetscape(r *bufio.Reader) bool {
+	head, _ := r.Peek(4096)
+	if bytes.HasPrefix(head, []byte(netscapeHeader)) || bytes.HasPrefix(head, []byte("# HTTP Cookie File")) {
+		return true
+	}
+	for _, line := range strings.Split(string(head), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || (strings.HasPrefix(line, "#") && !strings.HasPrefix(line, httpOnlyPrefix)) {
+			continue
+		}
+		return strings.Count(line, "\t") == 6
+	}
+	return false
+}
+
+const httpOnlyPrefix = "#HttpOnly_"
+
+// ParseNetscape parses cookies in the tab separated Netscape cookies.txt
+// format written by curl, wget and most cookie export extensions.
+// Domains of cookies which apply to subdomains start with a dot.
+func ParseNetscape(r io.Reader) ([]*http.Cookie, error) {
+	cookies := []*http.Cookie{}
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimRight(s.Text(), "\r")
+		httpOnly := strings.HasPrefix(line, httpOnlyPrefix)
+		if httpOnly {
+			line = strings.TrimPrefix(line, httpOnlyPrefix)
+		} else if strings.HasPrefix(line, "#") || strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("line %d: expected 7 tab separated fields, got %d", n, len(fields))
+		}
+		domain, subdomains, path, secure, expiry, name, value := fields[0], fields[1], fields[2], fields[3], fields[4], fields[5], fields[6]
+
+		c := &http.Cookie{
+			Name:     name,
+			Value:    value,
+			Path:     path,
+			Domain:   strings.TrimPrefix(domain, "."),
+			Secure:   strings.EqualFold(secure, "TRUE"),
+			HttpOnly: httpOnly,
+		}
+		if strings.EqualFold(subdomains, "TRUE") {
+			c.Domain = "." + c.Domain
+		}
+		exp, err := strconv.ParseInt(expiry, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid expiry %q", n, expiry)
+		}
+		if exp != 0 {
+			c.Expires = time.Unix(exp, 0)
+		}
+		cookies = append(cookies, c)
+	}
+	return cookies, s.Err()
+}
+
+// ParseHeader parses name=value pairs separated by "; " or newlines, as
+// copied from the Cookie header of a browser request. The cookies carry
+// no domain and are sent to every host.
+func ParseHeader(r io.Reader) ([]*http.Cookie, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	sep := strings.ReplaceAll(string(data), "; ", "\n")
+	cookies := []*http.Cookie{}
+	for i, pair := range strings.Split(sep, "\n") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("cookie %d has no value: %q", i+1, pair)
+		}
+		cookies = append(cookies, &http.Cookie{
+			Name:  name,
+			Value: value,
+		})
+	}
+	return cookies, nil
+}
@@ -0,0 +1,226 @@
+package cookie
+
+import (
+	"bytes"
+	"encoding/binary"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var (
+	farFuture = time.Unix(4102444800, 0)
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected []*http.Cookie
+	}{
+		{
+			path: "testdata/cookies.txt",
+			expected: []*http.Cookie{
+				{Name: "user_session", Value: "netscape-session", Domain: "github.com", Path: "/", Expires: farFuture, Secure: true, HttpOnly: true},
+				{Name: "logged_in", Value: "yes", Domain: ".github.com", Path: "/", Expires: farFuture, Secure: true},
+				{Name: "_gh_sess", Value: "session-cookie", Domain: ".github.com", Path: "/"},
+			},
+		},
+		{
+			path: "testdata/chromium.sqlite",
+			expected: []*http.Cookie{
+				{Name: "user_session", Value: "chromium-session", Domain: ".github.com", Path: "/", Expires: farFuture, Secure: true, HttpOnly: true},
+				{Name: "tz", Value: "Europe%2FBerlin", Domain: "github.com", Path: "/", Secure: true},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		cookies, err := Load(test.path)
+		if err != nil {
+			t.Fatalf("Loading %s failed: %s", test.path, err)
+		}
+		diff := cmp.Diff(cookies, test.expected, cmp.Comparer(func(a, b time.Time) bool {
+			return a.Equal(b)
+		}))
+		if diff != "" {
+			t.Errorf("Loading %s produced unexpected cookies:\n%s\n", test.path, diff)
+		}
+	}
+}
+
+func TestLoadFirefox(t *testing.T) {
+	cookies, err := Load("testdata/firefox.sqlite")
+	if err != nil {
+		t.Fatal("Loading failed:", err)
+	}
+
+	// The expired _octo cookie is dropped.
+	if len(cookies) != 203 {
+		t.Fatal("Unexpected number of cookies:", len(cookies))
+	}
+
+	diff := cmp.Diff(cookies[:2], []*http.Cookie{
+		{Name: "user_session", Value: "ff-session", Domain: "github.com", Path: "/", Expires: farFuture, Secure: true, HttpOnly: true},
+		{Name: "logged_in", Value: "yes", Domain: ".github.com", Path: "/", Expires: farFuture, Secure: true},
+	}, cmp.Comparer(func(a, b time.Time) bool {
+		return a.Equal(b)
+	}))
+	if diff != "" {
+		t.Errorf("Unexpected cookies:\n%s\n", diff)
+	}
+
+	// Stored on overflow pages.
+	if big := cookies[2]; big.Name != "big" || big.Value != strings.Repeat("x", 3000) {
+		t.Errorf("Unexpected overflowing cookie %s of length %d", big.Name, len(big.Value))
+	}
+	if last := cookies[len(cookies)-1]; last.Name != "filler199" || last.Value != "v199" {
+		t.Errorf("Unexpected last cookie %s=%s", last.Name, last.Value)
+	}
+}
+
+func TestLoadChromiumKeyring(t *testing.T) {
+	_, err := Load("testdata/chromium-keyring.sqlite")
+	if err == nil || !strings.Contains(err.Error(), "user_session") || !strings.Contains(err.Error(), "keyring secret (v11)") {
+		t.Error("Expected session cookie encrypted with the keyring to fail, got", err)
+	}
+}
+
+func TestLoadWAL(t *testing.T) {
+	cookies, err := Load("testdata/firefox-wal.sqlite")
+	if err != nil {
+		t.Fatal("Loading failed:", err)
+	}
+	diff := cmp.Diff(cookies, []*http.Cookie{
+		{Name: "user_session", Value: "renewed-session", Domain: "github.com", Path: "/", Expires: farFuture, Secure: true, HttpOnly: true},
+		{Name: "logged_in", Value: "yes", Domain: ".github.com", Path: "/", Expires: farFuture, Secure: true},
+	}, cmp.Comparer(func(a, b time.Time) bool {
+		return a.Equal(b)
+	}))
+	if diff != "" {
+		t.Errorf("Changes in the write-ahead log not applied:\n%s\n", diff)
+	}
+
+	// Without the log, only the checkpointed state is seen.
+	p := filepath.Join(t.TempDir(), "cookies.sqlite")
+	data, err := os.ReadFile("testdata/firefox-wal.sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	cookies, err = Load(p)
+	if err != nil {
+		t.Fatal("Loading failed:", err)
+	}
+	if len(cookies) != 1 || cookies[0].Value != "stale-session" {
+		t.Errorf("Unexpected cookies without write-ahead log: %v", cookies)
+	}
+}
+
+func TestLoadCorrupt(t *testing.T) {
+	data, err := os.ReadFile("testdata/firefox.sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Corrupt databases must fail, not panic.
+	load := func(data []byte) error {
+		db, err := parseSQLite(data)
+		if err != nil {
+			return err
+		}
+		_, err = loadDB(db)
+		return err
+	}
+
+	for n := 100; n < len(data); n += 256 {
+		if err := load(data[:n]); err == nil {
+			t.Errorf("Expected database truncated to %d bytes to fail", n)
+		}
+	}
+
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		corrupt := append([]byte(nil), data...)
+		for j := 0; j < 8; j++ {
+			corrupt[100+r.Intn(len(corrupt)-100)] = byte(r.Intn(256))
+		}
+		load(corrupt)
+	}
+
+	// Make the right child of the root page of moz_cookies the root page.
+	db, _ := parseSQLite(append([]byte(nil), data...))
+	table, err := db.table("moz_cookies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, _ := db.page(table.rootPage)
+	if root[0] != 0x05 {
+		t.Fatal("Expected interior root page, got page type", root[0])
+	}
+	binary.BigEndian.PutUint32(root[8:], table.rootPage)
+	if _, err := loadDB(db); err == nil || !strings.Contains(err.Error(), "referenced twice") {
+		t.Error("Expected cycle to fail, got", err)
+	}
+}
+
+func TestLoadWarnsExpiredSession(t *testing.T) {
+	buf := bytes.Buffer{}
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	cookies, err := Load("testdata/expired.txt")
+	if err != nil {
+		t.Fatal("Loading failed:", err)
+	}
+	if len(cookies) != 1 || cookies[0].Name != "logged_in" {
+		t.Errorf("Expected only logged_in, got %v", cookies)
+	}
+	if !strings.Contains(buf.String(), "user_session cookie for github.com") {
+		t.Errorf("Missing warning for expired session, log:\n%s", buf.String())
+	}
+}
+
+func TestLoadHeader(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "cookie.combined.txt")
+	err := os.WriteFile(p, []byte("user_session=abc; logged_in=yes\n_gh_sess=a=b\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cookies, err := Load(p)
+	if err != nil {
+		t.Fatal("Loading failed:", err)
+	}
+	diff := cmp.Diff(cookies, []*http.Cookie{
+		{Name: "user_session", Value: "abc"},
+		{Name: "logged_in", Value: "yes"},
+		{Name: "_gh_sess", Value: "a=b"},
+	})
+	if diff != "" {
+		t.Errorf("Unexpected cookies:\n%s\n", diff)
+	}
+
+	err = os.WriteFile(p, []byte("user_session\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Load(p)
+	if err == nil {
+		t.Error("Expected error for cookie without value")
+	}
+}
+
+func TestParseNetscapeMalformed(t *testing.T) {
+	_, err := ParseNetscape(strings.NewReader("# Netscape HTTP Cookie File\ngithub.com\tFALSE\t/\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Error("Expected error for line 2, got:", err)
+	}
+}
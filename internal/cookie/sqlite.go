@@ -0,0 +1,504 @@
+package cookie
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"strings"
+)
+
+// This file implements just enough of the SQLite file format to scan the
+// rows of a table in a browser's cookie database. Browsers keep these
+// databases locked while running, so copying the file and reading it
+// without a driver is the most reliable way in. Running browsers keep
+// recent changes in a write-ahead log (the -wal file next to the
+// database), its committed transactions are applied on top.
+
+const sqliteMagic = "SQLite format 3\x00"
+
+var errNotSQLite = errors.New("not an SQLite database")
+
+type sqliteDB struct {
+	data     []byte
+	pageSize int
+	usable   int
+	// size is the number of pages of the database.
+	size uint32
+	// wal holds the pages committed to the write-ahead log, which
+	// replace those in data.
+	wal map[uint32][]byte
+}
+
+func openSQLite(path string) (*sqliteDB, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	db, err := parseSQLite(data)
+	if err != nil {
+		return nil, err
+	}
+
+	wal, err := os.ReadFile(path + "-wal")
+	if errors.Is(err, fs.ErrNotExist) {
+		return db, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := db.applyWAL(wal); err != nil {
+		return nil, fmt.Errorf("%s-wal: %w", path, err)
+	}
+	return db, nil
+}
+
+func parseSQLite(data []byte) (*sqliteDB, error) {
+	if len(data) < 100 || string(data[:16]) != sqliteMagic {
+		return nil, errNotSQLite
+	}
+
+	pageSize := int(binary.BigEndian.Uint16(data[16:18]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	if pageSize < 512 || pageSize&(pageSize-1) != 0 {
+		return nil, fmt.Errorf("invalid page size %d", pageSize)
+	}
+	usable := pageSize - int(data[20])
+	if usable < 480 {
+		return nil, fmt.Errorf("invalid reserved space %d", data[20])
+	}
+	if enc := binary.BigEndian.Uint32(data[56:60]); enc > 1 {
+		return nil, fmt.Errorf("unsupported text encoding %d", enc)
+	}
+	// The size in pages is only valid if written by the same change as
+	// the file change counter.
+	if pages := binary.BigEndian.Uint32(data[28:32]); pages != 0 && string(data[24:28]) == string(data[92:96]) {
+		if int64(pages)*int64(pageSize) > int64(len(data)) {
+			return nil, fmt.Errorf("database truncated to %d of %d pages", len(data)/pageSize, pages)
+		}
+	}
+
+	return &sqliteDB{
+		data:     data,
+		pageSize: pageSize,
+		usable:   usable,
+		size:     uint32(len(data) / pageSize),
+	}, nil
+}
+
+func (db *sqliteDB) page(n uint32) ([]byte, error) {
+	if n == 0 || n > db.size {
+		return nil, fmt.Errorf("page %d out of range", n)
+	}
+	if p, ok := db.wal[n]; ok {
+		return p, nil
+	}
+	start := (int64(n) - 1) * int64(db.pageSize)
+	if start+int64(db.pageSize) > int64(len(db.data)) {
+		return nil, fmt.Errorf("page %d out of range", n)
+	}
+	return db.data[start : start+int64(db.pageSize)], nil
+}
+
+const (
+	walHeaderSize      = 32
+	walFrameHeaderSize = 24
+)
+
+// applyWAL applies the transactions committed to the write-ahead log wal,
+// like SQLite does when opening the database. Frames are read up to the
+// first one which does not belong to the log or fails its checksum.
+func (db *sqliteDB) applyWAL(wal []byte) error {
+	// An empty log has not been written to yet.
+	if len(wal) < walHeaderSize {
+		return nil
+	}
+
+	// The magic number tells the byte order checksums are computed in,
+	// all fields are big endian.
+	var order binary.ByteOrder
+	switch binary.BigEndian.Uint32(wal) {
+	case 0x377f0682:
+		order = binary.LittleEndian
+	case 0x377f0683:
+		order = binary.BigEndian
+	default:
+		return errors.New("not a write-ahead log")
+	}
+	if pageSize := binary.BigEndian.Uint32(wal[8:12]); int(pageSize) != db.pageSize {
+		return fmt.Errorf("page size %d differs from database page size %d", pageSize, db.pageSize)
+	}
+	s0, s1 := walChecksum(order, 0, 0, wal[:24])
+	if s0 != binary.BigEndian.Uint32(wal[24:]) || s1 != binary.BigEndian.Uint32(wal[28:]) {
+		// SQLite ignores logs with an invalid header.
+		return nil
+	}
+	salt := wal[16:24]
+
+	committed := map[uint32][]byte{}
+	pending := map[uint32][]byte{}
+	size := db.size
+	frameSize := walFrameHeaderSize + db.pageSize
+	for off := walHeaderSize; off+frameSize <= len(wal); off += frameSize {
+		hdr := wal[off : off+walFrameHeaderSize]
+		page := wal[off+walFrameHeaderSize : off+frameSize]
+		if !bytes.Equal(hdr[8:16], salt) {
+			break
+		}
+		s0, s1 = walChecksum(order, s0, s1, hdr[:8])
+		s0, s1 = walChecksum(order, s0, s1, page)
+		if s0 != binary.BigEndian.Uint32(hdr[16:]) || s1 != binary.BigEndian.Uint32(hdr[20:]) {
+			break
+		}
+
+		n := binary.BigEndian.Uint32(hdr)
+		if n == 0 {
+			break
+		}
+		pending[n] = page
+		// Commit frames carry the size of the database after the
+		// transaction.
+		if commit := binary.BigEndian.Uint32(hdr[4:]); commit != 0 {
+			for n, p := range pending {
+				committed[n] = p
+			}
+			pending = map[uint32][]byte{}
+			size = commit
+		}
+	}
+
+	db.wal = committed
+	db.size = size
+	return nil
+}
+
+// walChecksum continues the checksum s0, s1 over b, whose length is a
+// multiple of 8.
+func walChecksum(order binary.ByteOrder, s0, s1 uint32, b []byte) (uint32, uint32) {
+	for i := 0; i+8 <= len(b); i += 8 {
+		s0 += order.Uint32(b[i:]) + s1
+		s1 += order.Uint32(b[i+4:]) + s0
+	}
+	return s0, s1
+}
+
+// sqliteTable is a table found in the schema of a database.
+type sqliteTable struct {
+	rootPage uint32
+	columns  []string
+	// rowidColumn is the index of the INTEGER PRIMARY KEY column, which
+	// aliases the rowid and is stored as NULL, or -1.
+	rowidColumn int
+}
+
+// table looks up name in the sqlite_master table.
+func (db *sqliteDB) table(name string) (*sqliteTable, error) {
+	var found *sqliteTable
+	err := db.scan(1, func(rowid int64, values []interface{}) error {
+		if len(values) < 5 || values[0] != "table" || !strings.EqualFold(fmt.Sprint(values[1]), name) {
+			return nil
+		}
+		root, ok := values[3].(int64)
+		if !ok {
+			return fmt.Errorf("table %s has no root page", name)
+		}
+		sql, _ := values[4].(string)
+		columns, rowidColumn := parseColumns(sql)
+		found = &sqliteTable{
+			rootPage:    uint32(root),
+			columns:     columns,
+			rowidColumn: rowidColumn,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, fmt.Errorf("no table %s", name)
+	}
+	return found, nil
+}
+
+// rows calls fn with every row of t, keyed by column name.
+func (db *sqliteDB) rows(t *sqliteTable, fn func(row map[string]interface{}) error) error {
+	return db.scan(t.rootPage, func(rowid int64, values []interface{}) error {
+		row := make(map[string]interface{}, len(t.columns))
+		for i, c := range t.columns {
+			if i < len(values) {
+				row[c] = values[i]
+			}
+		}
+		if t.rowidColumn >= 0 {
+			row[t.columns[t.rowidColumn]] = rowid
+		}
+		return fn(row)
+	})
+}
+
+// maxTreeDepth limits the depth of b-trees scan descends into. Real
+// databases stay far below it.
+const maxTreeDepth = 64
+
+// scan walks the table b-tree rooted at page root in rowid order.
+func (db *sqliteDB) scan(root uint32, fn func(rowid int64, values []interface{}) error) error {
+	return db.scanPage(root, map[uint32]bool{}, 0, fn)
+}
+
+// scanPage walks the subtree rooted at page n. visited holds the pages
+// walked so far, which no valid b-tree visits twice.
+func (db *sqliteDB) scanPage(n uint32, visited map[uint32]bool, depth int, fn func(rowid int64, values []interface{}) error) error {
+	if visited[n] {
+		return fmt.Errorf("page %d is referenced twice", n)
+	}
+	if depth > maxTreeDepth {
+		return fmt.Errorf("page %d is nested too deeply", n)
+	}
+	visited[n] = true
+	page, err := db.page(n)
+	if err != nil {
+		return err
+	}
+
+	hdr := 0
+	if n == 1 {
+		hdr = 100
+	}
+
+	cells := int(binary.BigEndian.Uint16(page[hdr+3 : hdr+5]))
+	switch page[hdr] {
+	case 0x05: // interior table page
+		ptrs := hdr + 12
+		for i := 0; i < cells; i++ {
+			cell, err := cellAt(page, ptrs, i)
+			if err != nil {
+				return fmt.Errorf("page %d: %w", n, err)
+			}
+			if len(cell) < 4 {
+				return fmt.Errorf("page %d: cell %d truncated", n, i)
+			}
+			if err := db.scanPage(binary.BigEndian.Uint32(cell), visited, depth+1, fn); err != nil {
+				return err
+			}
+		}
+		return db.scanPage(binary.BigEndian.Uint32(page[hdr+8:]), visited, depth+1, fn)
+	case 0x0d: // leaf table page
+		ptrs := hdr + 8
+		for i := 0; i < cells; i++ {
+			cell, err := cellAt(page, ptrs, i)
+			if err != nil {
+				return fmt.Errorf("page %d: %w", n, err)
+			}
+			size, k := readVarint(cell)
+			cell = cell[k:]
+			rowid, k := readVarint(cell)
+			cell = cell[k:]
+			payload, err := db.payload(cell, size, visited)
+			if err != nil {
+				return fmt.Errorf("page %d: row %d: %w", n, rowid, err)
+			}
+			values, err := parseRecord(payload)
+			if err != nil {
+				return fmt.Errorf("row %d: %w", rowid, err)
+			}
+			if err := fn(rowid, values); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("page %d is not a table b-tree page", n)
+	}
+}
+
+// cellAt returns page from the start of cell i on. The cell pointer array
+// starts at offset ptrs.
+func cellAt(page []byte, ptrs, i int) ([]byte, error) {
+	if ptrs+2*i+2 > len(page) {
+		return nil, fmt.Errorf("cell pointer %d out of range", i)
+	}
+	off := int(binary.BigEndian.Uint16(page[ptrs+2*i:]))
+	if off < ptrs || off >= len(page) {
+		return nil, fmt.Errorf("cell %d out of range", i)
+	}
+	return page[off:], nil
+}
+
+// payload assembles a cell payload of size bytes, following overflow
+// pages if it does not fit into the page. Overflow pages are added to
+// visited.
+func (db *sqliteDB) payload(cell []byte, size int64, visited map[uint32]bool) ([]byte, error) {
+	if size < 0 || size > int64(db.size)*int64(db.usable) {
+		return nil, fmt.Errorf("invalid payload size %d", size)
+	}
+	maxLocal := db.usable - 35
+	if size <= int64(maxLocal) {
+		if int(size) > len(cell) {
+			return nil, errors.New("payload exceeds page")
+		}
+		return cell[:size], nil
+	}
+
+	minLocal := (db.usable-12)*32/255 - 23
+	local := minLocal + (int(size)-minLocal)%(db.usable-4)
+	if local > maxLocal {
+		local = minLocal
+	}
+	if local+4 > len(cell) {
+		return nil, errors.New("payload exceeds page")
+	}
+
+	payload := make([]byte, 0, size)
+	payload = append(payload, cell[:local]...)
+	next := binary.BigEndian.Uint32(cell[local:])
+	for int64(len(payload)) < size {
+		if visited[next] {
+			return nil, fmt.Errorf("overflow page %d is referenced twice", next)
+		}
+		visited[next] = true
+		page, err := db.page(next)
+		if err != nil {
+			return nil, fmt.Errorf("overflow: %w", err)
+		}
+		next = binary.BigEndian.Uint32(page)
+		n := int(size) - len(payload)
+		if n > db.usable-4 {
+			n = db.usable - 4
+		}
+		payload = append(payload, page[4:4+n]...)
+	}
+	return payload, nil
+}
+
+// parseRecord decodes a record into int64, float64, string, []byte or
+// nil values.
+func parseRecord(rec []byte) ([]interface{}, error) {
+	hdrSize, n := readVarint(rec)
+	if hdrSize < 0 || hdrSize > int64(len(rec)) {
+		return nil, errors.New("malformed record header")
+	}
+
+	types := []int64{}
+	for off := n; off < int(hdrSize); {
+		t, n := readVarint(rec[off:])
+		types = append(types, t)
+		off += n
+	}
+
+	values := make([]interface{}, 0, len(types))
+	body := rec[hdrSize:]
+	for _, t := range types {
+		var size int
+		switch {
+		case t == 0, t == 8, t == 9:
+			size = 0
+		case t >= 1 && t <= 4:
+			size = int(t)
+		case t == 5:
+			size = 6
+		case t == 6, t == 7:
+			size = 8
+		case t >= 12:
+			size = int(t-12) / 2
+		default:
+			return nil, fmt.Errorf("invalid serial type %d", t)
+		}
+		if size > len(body) {
+			return nil, errors.New("record exceeds payload")
+		}
+		v := body[:size]
+		body = body[size:]
+
+		switch {
+		case t == 0:
+			values = append(values, nil)
+		case t == 8:
+			values = append(values, int64(0))
+		case t == 9:
+			values = append(values, int64(1))
+		case t <= 6:
+			// Sign extend the big endian two's complement integer.
+			i := int64(int8(v[0]))
+			for _, b := range v[1:] {
+				i = i<<8 | int64(b)
+			}
+			values = append(values, i)
+		case t == 7:
+			values = append(values, math.Float64frombits(binary.BigEndian.Uint64(v)))
+		case t%2 == 0:
+			values = append(values, append([]byte(nil), v...))
+		default:
+			values = append(values, string(v))
+		}
+	}
+	return values, nil
+}
+
+func readVarint(b []byte) (int64, int) {
+	var v uint64
+	for i := 0; i < 8 && i < len(b); i++ {
+		v = v<<7 | uint64(b[i]&0x7f)
+		if b[i] < 0x80 {
+			return int64(v), i + 1
+		}
+	}
+	if len(b) < 9 {
+		return int64(v), len(b)
+	}
+	return int64(v<<8 | uint64(b[8])), 9
+}
+
+// parseColumns extracts the column names from a CREATE TABLE statement.
+func parseColumns(sql string) (columns []string, rowidColumn int) {
+	rowidColumn = -1
+	start := strings.IndexByte(sql, '(')
+	end := strings.LastIndexByte(sql, ')')
+	if start < 0 || end < start {
+		return nil, rowidColumn
+	}
+
+	defs := []string{}
+	depth := 0
+	last := start + 1
+	for i := start + 1; i < end; i++ {
+		switch sql[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				defs = append(defs, sql[last:i])
+				last = i + 1
+			}
+		}
+	}
+	defs = append(defs, sql[last:end])
+
+	for _, def := range defs {
+		fields := strings.Fields(def)
+		if len(fields) == 0 {
+			continue
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "PRIMARY", "UNIQUE", "CHECK", "FOREIGN", "CONSTRAINT":
+			continue
+		}
+		name := strings.Trim(fields[0], "\"`[]")
+		upper := strings.ToUpper(strings.Join(fields[1:], " "))
+		if strings.HasPrefix(upper, "INTEGER PRIMARY KEY") {
+			rowidColumn = len(columns)
+		}
+		columns = append(columns, name)
+	}
+	return columns, rowidColumn
+}
+
+func isSQLite(head []byte) bool {
+	return bytes.HasPrefix(head, []byte(sqliteMagic))
+}
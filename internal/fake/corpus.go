@@ -0,0 +1,180 @@
+package fake
+
+import (
+	"bufio"
+	"crypto/sha1"
+	_ "embed"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// File is a file of a repository in a Corpus.
+type File struct {
+	// Repo is the repository as owner/name.
+	Repo     string
+	Path     string
+	Language string
+	Content  string
+}
+
+// Owner returns the owner of the repository of f.
+func (f *File) Owner() string {
+	owner, _, _ := strings.Cut(f.Repo, "/")
+	return owner
+}
+
+// RepoId returns a stable id of the repository of f.
+func (f *File) RepoId() uint64 {
+	h := fnv.New32a()
+	h.Write([]byte(f.Repo))
+	return uint64(h.Sum32())
+}
+
+// Sha returns the git blob id of f.
+func (f *File) Sha() string {
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", len(f.Content))
+	h.Write([]byte(f.Content))
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// CommitSha returns a stable stand-in for the head commit of the
+// repository of f.
+func (f *File) CommitSha() string {
+	return fmt.Sprintf("%x", sha1.Sum([]byte(f.Repo)))
+}
+
+// Corpus is the set of files a Server searches, sorted by repository and
+// path.
+type Corpus []*File
+
+//go:embed corpus.txtar
+var defaultCorpus string
+
+// DefaultCorpus returns a small corpus covering the built-in presets.
+func DefaultCorpus() Corpus {
+	c, err := ParseCorpus(strings.NewReader(defaultCorpus))
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// ParseCorpus reads a corpus from an archive of files, each introduced by
+// a line "-- owner/repo/path --". Text before the first file is ignored.
+func ParseCorpus(r io.Reader) (Corpus, error) {
+	c := Corpus{}
+	var f *File
+	content := strings.Builder{}
+	flush := func() {
+		if f != nil {
+			f.Content = content.String()
+			c = append(c, f)
+		}
+		content.Reset()
+	}
+
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	for s.Scan() {
+		line := s.Text()
+		if strings.HasPrefix(line, "-- ") && strings.HasSuffix(line, " --") && len(line) > 6 {
+			flush()
+			var err error
+			f, err = newFile(strings.TrimSpace(line[3 : len(line)-3]))
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if f != nil {
+			content.WriteString(line)
+			content.WriteByte('\n')
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+	c.sort()
+	return c, nil
+}
+
+// CorpusFS reads a corpus from a file system laid out as
+// owner/repo/path.
+func CorpusFS(fsys fs.FS) (Corpus, error) {
+	c := Corpus{}
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		f, err := newFile(p)
+		if err != nil {
+			return err
+		}
+		f.Content = string(data)
+		c = append(c, f)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	c.sort()
+	return c, nil
+}
+
+func newFile(name string) (*File, error) {
+	parts := strings.SplitN(name, "/", 3)
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return nil, fmt.Errorf("corpus file %q is not owner/repo/path", name)
+	}
+	return &File{
+		Repo:     parts[0] + "/" + parts[1],
+		Path:     parts[2],
+		Language: languageOf(parts[2]),
+	}, nil
+}
+
+func (c Corpus) sort() {
+	sort.SliceStable(c, func(i, j int) bool {
+		if c[i].Repo != c[j].Repo {
+			return c[i].Repo < c[j].Repo
+		}
+		return c[i].Path < c[j].Path
+	})
+}
+
+var languages = map[string]string{
+	".bazel":        "Starlark",
+	".go":           "Go",
+	".java":         "Java",
+	".md":           "Markdown",
+	".mod":          "Go Module",
+	".proto":        "Protocol Buffer",
+	".rs":           "Rust",
+	".tf":           "HCL",
+	".toml":         "TOML",
+	".yaml":         "YAML",
+	".yml":          "YAML",
+	"BUILD":         "Starlark",
+	"Containerfile": "Dockerfile",
+	"Dockerfile":    "Dockerfile",
+}
+
+// languageOf guesses the language of the file p by its name.
+func languageOf(p string) string {
+	base := path.Base(p)
+	if l, ok := languages[base]; ok {
+		return l
+	}
+	return languages[path.Ext(base)]
+}
@@ -0,0 +1,58 @@
+package fake
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseCorpus(t *testing.T) {
+	c, err := ParseCorpus(strings.NewReader(`Comment
+-- initech/tps/b.go --
+package b
+-- acme/widgets/README.md --
+# widgets
+
+-- acme/widgets/BUILD --
+`))
+	if err != nil {
+		t.Fatal("ParseCorpus failed:", err)
+	}
+	diff := cmp.Diff(c, Corpus{
+		{Repo: "acme/widgets", Path: "BUILD", Language: "Starlark", Content: ""},
+		{Repo: "acme/widgets", Path: "README.md", Language: "Markdown", Content: "# widgets\n\n"},
+		{Repo: "initech/tps", Path: "b.go", Language: "Go", Content: "package b\n"},
+	})
+	if diff != "" {
+		t.Errorf("Unexpected corpus:\n%s\n", diff)
+	}
+
+	_, err = ParseCorpus(strings.NewReader("-- toplevel.go --\n"))
+	if err == nil {
+		t.Error("Expected file outside of a repository to fail")
+	}
+}
+
+func TestCorpusFS(t *testing.T) {
+	c, err := CorpusFS(fstest.MapFS{
+		"acme/widgets/go.mod": {Data: []byte("module github.com/acme/widgets\n")},
+	})
+	if err != nil {
+		t.Fatal("CorpusFS failed:", err)
+	}
+	if len(c) != 1 || c[0].Repo != "acme/widgets" || c[0].Path != "go.mod" || c[0].Language != "Go Module" {
+		t.Errorf("Unexpected corpus: %+v", c)
+	}
+}
+
+func TestFileIds(t *testing.T) {
+	f := &File{Repo: "acme/widgets", Content: "hello\n"}
+	if sha := f.Sha(); sha != "ce013625030ba8dba906f756967f9e9ca394464a" {
+		t.Error("Sha is not the git blob id:", sha)
+	}
+	if f.Owner() != "acme" || f.RepoId() == 0 {
+		t.Errorf("Unexpected owner %s or repo id %d", f.Owner(), f.RepoId())
+	}
+}
@@ -0,0 +1,260 @@
+package fake
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/abergmeier/knollledge/internal/query"
+)
+
+// matcher evaluates a parsed query against files the way code search
+// does, closely enough for tests: terms match paths and contents case
+// insensitively, path globs are anchored and qualifiers compare names.
+type matcher struct {
+	n     query.Node
+	regex map[string]*regexp.Regexp
+}
+
+// newMatcher compiles the query q. Errors carry the offset of the
+// problem, if known.
+func newMatcher(q string) (*matcher, *queryError) {
+	n, err := query.Parse(q)
+	if serr, ok := err.(*query.SyntaxError); ok {
+		return nil, &queryError{Message: serr.Msg, Position: serr.Offset}
+	}
+	if err != nil {
+		return nil, &queryError{Message: err.Error(), Position: -1}
+	}
+
+	m := &matcher{
+		n:     n,
+		regex: map[string]*regexp.Regexp{},
+	}
+	if qerr := m.compile(n); qerr != nil {
+		return nil, qerr
+	}
+	return m, nil
+}
+
+// compile checks the qualifiers of n and compiles its regular
+// expressions and globs.
+func (m *matcher) compile(n query.Node) *queryError {
+	switch n := n.(type) {
+	case query.And:
+		for _, c := range n.Nodes {
+			if qerr := m.compile(c); qerr != nil {
+				return qerr
+			}
+		}
+	case query.Or:
+		for _, c := range n.Nodes {
+			if qerr := m.compile(c); qerr != nil {
+				return qerr
+			}
+		}
+	case query.Not:
+		return m.compile(n.Node)
+	case query.Regex:
+		return m.compileRegex(n.Pattern)
+	case query.Qualifier:
+		switch n.Key {
+		case query.KeyPath, query.KeyRepo, query.KeyOrg, query.KeyUser, query.KeyLanguage, query.KeySymbol, query.KeyContent:
+		default:
+			return &queryError{Message: fmt.Sprintf("unknown qualifier %q", n.Key), Position: -1}
+		}
+		switch v := n.Value.(type) {
+		case query.Regex:
+			return m.compileRegex(v.Pattern)
+		case query.Text:
+			if n.Key == query.KeyPath && isGlob(v.Value) {
+				return m.compileRegex(globToRegex(v.Value))
+			}
+		}
+	}
+	return nil
+}
+
+func (m *matcher) compileRegex(pattern string) *queryError {
+	if _, ok := m.regex[pattern]; ok {
+		return nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return &queryError{Message: fmt.Sprintf("invalid regular expression: %s", err), Position: -1}
+	}
+	m.regex[pattern] = re
+	return nil
+}
+
+// match tells whether f matches and returns the byte ranges of the
+// content matches.
+func (m *matcher) match(f *File) (bool, [][2]int) {
+	ranges := [][2]int{}
+	ok := m.eval(m.n, f, &ranges, false)
+	return ok, ranges
+}
+
+// eval evaluates n against f. Ranges of content matches are collected
+// unless negated.
+func (m *matcher) eval(n query.Node, f *File, ranges *[][2]int, negated bool) bool {
+	collect := func(rs [][2]int) bool {
+		if !negated {
+			*ranges = append(*ranges, rs...)
+		}
+		return len(rs) != 0
+	}
+
+	switch n := n.(type) {
+	case query.And:
+		for _, c := range n.Nodes {
+			if !m.eval(c, f, ranges, negated) {
+				return false
+			}
+		}
+		return true
+	case query.Or:
+		matched := false
+		for _, c := range n.Nodes {
+			if m.eval(c, f, ranges, negated) {
+				matched = true
+			}
+		}
+		return matched
+	case query.Not:
+		return !m.eval(n.Node, f, ranges, !negated)
+	case query.Text:
+		matched := collect(indexAll(f.Content, n.Value))
+		return matched || containsFold(f.Path, n.Value)
+	case query.Regex:
+		re := m.regex[n.Pattern]
+		matched := collect(toRanges(re.FindAllStringIndex(f.Content, -1)))
+		return matched || re.MatchString(f.Path)
+	case query.Qualifier:
+		return m.evalQualifier(n, f, collect)
+	}
+	return false
+}
+
+func (m *matcher) evalQualifier(q query.Qualifier, f *File, collect func([][2]int) bool) bool {
+	value := ""
+	var re *regexp.Regexp
+	switch v := q.Value.(type) {
+	case query.Text:
+		value = v.Value
+	case query.Regex:
+		re = m.regex[v.Pattern]
+	}
+
+	switch q.Key {
+	case query.KeyPath:
+		if re == nil && isGlob(value) {
+			re = m.regex[globToRegex(value)]
+		}
+		if re != nil {
+			return re.MatchString(f.Path)
+		}
+		return containsFold(f.Path, value)
+	case query.KeyRepo:
+		return strings.EqualFold(f.Repo, value)
+	case query.KeyOrg, query.KeyUser:
+		return strings.EqualFold(f.Owner(), value)
+	case query.KeyLanguage:
+		return strings.EqualFold(f.Language, value)
+	case query.KeySymbol:
+		return collect(symbolRanges(f.Content, value))
+	case query.KeyContent:
+		if re != nil {
+			return collect(toRanges(re.FindAllStringIndex(f.Content, -1)))
+		}
+		return collect(indexAll(f.Content, value))
+	}
+	return false
+}
+
+// symbolRanges returns the ranges of definitions of name.
+func symbolRanges(content, name string) [][2]int {
+	re := regexp.MustCompile(`\b(?:class|interface|func|type|message|def|fn|struct)\s+(` + regexp.QuoteMeta(name) + `)\b`)
+	ranges := [][2]int{}
+	for _, m := range re.FindAllStringSubmatchIndex(content, -1) {
+		ranges = append(ranges, [2]int{m[2], m[3]})
+	}
+	return ranges
+}
+
+// indexAll returns the ranges of all case insensitive occurrences of s.
+func indexAll(content, s string) [][2]int {
+	ranges := [][2]int{}
+	if s == "" {
+		return ranges
+	}
+	lower := strings.ToLower(content)
+	s = strings.ToLower(s)
+	for i := 0; ; {
+		j := strings.Index(lower[i:], s)
+		if j < 0 {
+			return ranges
+		}
+		ranges = append(ranges, [2]int{i + j, i + j + len(s)})
+		i += j + len(s)
+	}
+}
+
+func toRanges(idx [][]int) [][2]int {
+	ranges := make([][2]int, len(idx))
+	for i, r := range idx {
+		ranges[i] = [2]int{r[0], r[1]}
+	}
+	return ranges
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+func isGlob(s string) bool {
+	return strings.ContainsAny(s, "*?[")
+}
+
+// globToRegex translates a path glob into an anchored regular expression.
+// ** matches across directories, * and ? within one. Globs not starting
+// with / match at any directory.
+func globToRegex(glob string) string {
+	b := strings.Builder{}
+	if strings.HasPrefix(glob, "/") {
+		b.WriteString("^")
+		glob = glob[1:]
+	} else {
+		b.WriteString("(?:^|/)")
+	}
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+				if i+1 < len(glob) && glob[i+1] == '/' {
+					i++
+					b.WriteString("(?:.*/)?")
+				} else {
+					b.WriteString(".*")
+				}
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			b.WriteString(glob[i : i+end+1])
+			i += end
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
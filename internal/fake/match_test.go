@@ -0,0 +1,100 @@
+package fake
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func matchingPaths(t *testing.T, q string) []string {
+	m, qerr := newMatcher(q)
+	if qerr != nil {
+		t.Fatalf("Compiling %q failed: %s", q, qerr.Message)
+	}
+	paths := []string{}
+	for _, f := range DefaultCorpus() {
+		if ok, _ := m.match(f); ok {
+			paths = append(paths, f.Repo+"/"+f.Path)
+		}
+	}
+	return paths
+}
+
+func TestMatch(t *testing.T) {
+	tests := map[string][]string{
+		"path:**/go.mod": {
+			"acme/widgets/go.mod",
+			"initech/tps/go.mod",
+		},
+		"org:acme path:**/go.mod": {
+			"acme/widgets/go.mod",
+		},
+		"(path:**/Containerfile OR path:**/Dockerfile) FROM": {
+			"acme/infra/deploy/Containerfile",
+			"acme/widgets/Dockerfile",
+		},
+		"path:**/BUILD OR path:**/BUILD.bazel": {
+			"acme/widgets/BUILD.bazel",
+		},
+		`path:*.tf "google_container_cluster"`: {
+			"acme/infra/main.tf",
+		},
+		"language:java symbol:Widget": {
+			"acme/widgets/src/main/java/com/acme/Widget.java",
+		},
+		"path:**.java class NOT repo:acme/widgets": {
+			"initech/tps/src/tps/CoverSheet.java",
+			"initech/tps/src/tps/Report.java",
+			"initech/tps/src/tps/Stapler.java",
+		},
+		`content:/memo\?/ OR path:/^api\//`: {
+			"initech/tps/api/tps.proto",
+			"initech/tps/src/tps/CoverSheet.java",
+		},
+	}
+	for q, expected := range tests {
+		if diff := cmp.Diff(matchingPaths(t, q), expected); diff != "" {
+			t.Errorf("Unexpected matches of %q:\n%s\n", q, diff)
+		}
+	}
+}
+
+func TestMatchRanges(t *testing.T) {
+	m, _ := newMatcher("class NOT widget")
+	f := &File{Repo: "a/b", Path: "X.java", Content: "class X {}\n// subclass\n"}
+	ok, ranges := m.match(f)
+	if !ok {
+		t.Fatal("File did not match")
+	}
+	if diff := cmp.Diff(ranges, [][2]int{{0, 5}, {17, 22}}); diff != "" {
+		t.Errorf("Unexpected ranges:\n%s\n", diff)
+	}
+}
+
+func TestMatchErrors(t *testing.T) {
+	for q, pos := range map[string]int{
+		"(path:**/go.mod": 0,
+		"pth:**/go.mod":   -1,
+		"/[a-/":           -1,
+	} {
+		_, qerr := newMatcher(q)
+		if qerr == nil || qerr.Position != pos {
+			t.Errorf("Unexpected error for %q: %+v", q, qerr)
+		}
+	}
+}
+
+func TestGlobToRegex(t *testing.T) {
+	tests := map[string]string{
+		"**/go.mod": `(?:^|/)(?:.*/)?go\.mod$`,
+		"*.tf":      `(?:^|/)[^/]*\.tf$`,
+		"/src/?.go": `^src/[^/]\.go$`,
+		"**.java":   `(?:^|/).*\.java$`,
+		"[ab].go":   `(?:^|/)[ab]\.go$`,
+	}
+	for glob, expected := range tests {
+		if re := globToRegex(glob); re != expected {
+			t.Errorf("Unexpected regex of %s: %s", glob, re)
+		}
+	}
+}
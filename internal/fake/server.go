@@ -0,0 +1,423 @@
+// Package fake implements an in-process stand-in for the cs.github.com
+// search API, so clients and jobs can be tested without a browser session
+// or network access.
+//
+//	srv := fake.NewServer(fake.DefaultCorpus())
+//	defer srv.Close()
+//	c := github.NewClient(nil)
+//	c.CodeSearchURL, _ = url.Parse(srv.CodeSearchURL())
+//
+// The wire format is defined here independently of internal/github, so
+// tests against the fake catch decoding mistakes of the client.
+package fake
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fault is a failure the Server answers a request with instead of
+// searching.
+type Fault int
+
+const (
+	// FaultAccepted answers 202 Accepted with an empty body, as code
+	// search does while it computes a result.
+	FaultAccepted Fault = iota + 1
+	// FaultSecondaryRateLimit answers 403 with a secondary rate limit
+	// error and a Retry-After header.
+	FaultSecondaryRateLimit
+	// FaultServerError answers 502 Bad Gateway.
+	FaultServerError
+)
+
+const (
+	DefaultPageSize = 20
+	DefaultMaxPages = 5
+)
+
+// Server serves /api/search over a Corpus. Its configuration fields must
+// be set before the first request.
+type Server struct {
+	*httptest.Server
+
+	Corpus Corpus
+	// PageSize is the number of results per page, DefaultPageSize if 0.
+	PageSize int
+	// MaxPages limits total_pages like code search does, DefaultMaxPages
+	// if 0.
+	MaxPages int
+	// RateLimit is the number of requests allowed per RateWindow. 0 omits
+	// the rate limit headers and never limits.
+	RateLimit  int
+	RateWindow time.Duration
+	// RetryAfter is sent with FaultSecondaryRateLimit.
+	RetryAfter time.Duration
+
+	mu        sync.Mutex
+	faults    []Fault
+	requests  int
+	queries   []string
+	remaining int
+	reset     time.Time
+}
+
+// NewServer starts a Server searching c.
+func NewServer(c Corpus) *Server {
+	s := NewUnstartedServer(c)
+	s.Start()
+	return s
+}
+
+// NewUnstartedServer returns a Server searching c, which the caller has
+// to start.
+func NewUnstartedServer(c Corpus) *Server {
+	s := &Server{
+		Corpus:     c,
+		RateWindow: time.Hour,
+		RetryAfter: time.Second,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/search", s.search)
+	s.Server = httptest.NewUnstartedServer(mux)
+	return s
+}
+
+// CodeSearchURL returns the URL to configure as client CodeSearchURL.
+func (s *Server) CodeSearchURL() string {
+	return s.URL + "/api/"
+}
+
+// Fail queues faults. Each of the following requests is answered with the
+// next queued fault until there are none left.
+func (s *Server) Fail(faults ...Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, faults...)
+}
+
+// Queries returns the q parameter of every request so far.
+func (s *Server) Queries() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.queries...)
+}
+
+// Wire types of the search API.
+type (
+	searchResponse struct {
+		Error             string        `json:"error,omitempty"`
+		Failed            bool          `json:"failed,omitempty"`
+		RequestId         string        `json:"request_id"`
+		ResultsCount      int           `json:"results_count"`
+		IsTreelightsAvail bool          `json:"is_treelights_avail"`
+		SearchElapsedMs   int           `json:"search_elapsed_ms"`
+		Facets            []facetGroup  `json:"facets"`
+		QueryErrors       []*queryError `json:"query_errors,omitempty"`
+		PageToken         string        `json:"page_token,omitempty"`
+		PageNumber        int           `json:"page_number"`
+		TotalPages        int           `json:"total_pages"`
+		Results           []result      `json:"results"`
+	}
+
+	result struct {
+		Path       string    `json:"path"`
+		Sha        string    `json:"sha"`
+		RefName    string    `json:"ref_name"`
+		Language   string    `json:"language,omitempty"`
+		RepoId     uint64    `json:"repo_id"`
+		CommitSha  string    `json:"commit_sha"`
+		RepoName   string    `json:"repo_name"`
+		Snippets   []snippet `json:"snippets"`
+		MatchCount int       `json:"match_count"`
+		Matches    []match   `json:"matches"`
+	}
+
+	snippet struct {
+		Lines     []string `json:"lines"`
+		StartLine int      `json:"start_line"`
+	}
+
+	match struct {
+		Start int `json:"start"`
+		End   int `json:"end"`
+	}
+
+	facetGroup struct {
+		Kind   string  `json:"kind"`
+		Facets []facet `json:"facets"`
+	}
+
+	facet struct {
+		Name        string  `json:"name"`
+		Owner       string  `json:"owner,omitempty"`
+		Query       string  `json:"query"`
+		Occurrences int     `json:"occurrences"`
+		Score       float64 `json:"score"`
+	}
+
+	queryError struct {
+		Message  string `json:"message"`
+		Position int    `json:"position"`
+	}
+
+	errorResponse struct {
+		Message          string `json:"message"`
+		DocumentationURL string `json:"documentation_url,omitempty"`
+	}
+)
+
+func (s *Server) search(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests++
+	requestId := fmt.Sprint("FAKE:", s.requests)
+	q := r.URL.Query().Get("q")
+	s.queries = append(s.queries, q)
+	limited := s.rateLimit(w)
+	var fault Fault
+	if len(s.faults) != 0 && !limited {
+		fault, s.faults = s.faults[0], s.faults[1:]
+	}
+	s.mu.Unlock()
+
+	w.Header().Set("X-GitHub-Request-Id", requestId)
+	if limited {
+		writeJSON(w, http.StatusForbidden, &errorResponse{
+			Message:          "API rate limit exceeded",
+			DocumentationURL: "https://docs.github.com/rest/overview/resources-in-the-rest-api#rate-limiting",
+		})
+		return
+	}
+	switch fault {
+	case FaultAccepted:
+		w.WriteHeader(http.StatusAccepted)
+		return
+	case FaultSecondaryRateLimit:
+		w.Header().Set("Retry-After", strconv.Itoa(int(s.RetryAfter/time.Second)))
+		writeJSON(w, http.StatusForbidden, &errorResponse{
+			Message:          "You have exceeded a secondary rate limit.",
+			DocumentationURL: "https://docs.github.com/rest/overview/resources-in-the-rest-api#secondary-rate-limits",
+		})
+		return
+	case FaultServerError:
+		writeJSON(w, http.StatusBadGateway, &errorResponse{Message: "Bad Gateway"})
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, &errorResponse{Message: "Method Not Allowed"})
+		return
+	}
+
+	page := 1
+	if p := r.URL.Query().Get("p"); p != "" {
+		var err error
+		page, err = strconv.Atoi(p)
+		if err != nil || page < 1 {
+			writeJSON(w, http.StatusBadRequest, &errorResponse{Message: fmt.Sprintf("invalid page %q", p)})
+			return
+		}
+	}
+	if page > 1 && r.URL.Query().Get("pageToken") != pageToken(q, page-1) {
+		writeJSON(w, http.StatusBadRequest, &errorResponse{Message: "invalid page token"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.respond(q, page, requestId))
+}
+
+// rateLimit sets the rate limit headers and tells whether the request
+// exceeds the rate limit. s.mu must be held.
+func (s *Server) rateLimit(w http.ResponseWriter) bool {
+	if s.RateLimit == 0 {
+		return false
+	}
+	now := time.Now()
+	if now.After(s.reset) {
+		s.remaining = s.RateLimit
+		s.reset = now.Add(s.RateWindow)
+	}
+	limited := s.remaining == 0
+	if !limited {
+		s.remaining--
+	}
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(s.RateLimit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(s.remaining))
+	w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(s.reset.Unix(), 10))
+	return limited
+}
+
+// respond searches the corpus for page of q.
+func (s *Server) respond(q string, page int, requestId string) *searchResponse {
+	resp := &searchResponse{
+		RequestId:         requestId,
+		IsTreelightsAvail: true,
+		PageNumber:        page,
+		Facets:            []facetGroup{},
+		Results:           []result{},
+	}
+	if strings.TrimSpace(q) == "" {
+		resp.Failed = true
+		resp.Error = "query is empty"
+		return resp
+	}
+	m, qerr := newMatcher(q)
+	if qerr != nil {
+		resp.Failed = true
+		resp.Error = "invalid query"
+		resp.QueryErrors = []*queryError{qerr}
+		return resp
+	}
+
+	results := []result{}
+	for _, f := range s.Corpus {
+		ok, ranges := m.match(f)
+		if ok {
+			results = append(results, newResult(f, ranges))
+		}
+	}
+
+	pageSize := s.PageSize
+	if pageSize == 0 {
+		pageSize = DefaultPageSize
+	}
+	maxPages := s.MaxPages
+	if maxPages == 0 {
+		maxPages = DefaultMaxPages
+	}
+	resp.ResultsCount = len(results)
+	resp.TotalPages = (len(results) + pageSize - 1) / pageSize
+	if resp.TotalPages > maxPages {
+		resp.TotalPages = maxPages
+	}
+	resp.Facets = facets(results)
+	if page < resp.TotalPages {
+		resp.PageToken = pageToken(q, page)
+	}
+	if page <= resp.TotalPages {
+		end := page * pageSize
+		if end > len(results) {
+			end = len(results)
+		}
+		resp.Results = results[(page-1)*pageSize : end]
+	}
+	return resp
+}
+
+// pageToken returns the token handed out with page of q.
+func pageToken(q string, page int) string {
+	return fmt.Sprintf("%x", sha1.Sum([]byte(fmt.Sprint(q, "\x00", page))))
+}
+
+func newResult(f *File, ranges [][2]int) result {
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i][0] < ranges[j][0]
+	})
+	r := result{
+		Path:       f.Path,
+		Sha:        f.Sha(),
+		RefName:    "refs/heads/main",
+		Language:   f.Language,
+		RepoId:     f.RepoId(),
+		CommitSha:  f.CommitSha(),
+		RepoName:   f.Repo,
+		Snippets:   []snippet{},
+		MatchCount: len(ranges),
+		Matches:    make([]match, len(ranges)),
+	}
+	for i, m := range ranges {
+		r.Matches[i] = match{Start: m[0], End: m[1]}
+	}
+	if len(ranges) != 0 {
+		r.Snippets = append(r.Snippets, newSnippet(f.Content, ranges))
+	}
+	return r
+}
+
+// newSnippet renders the line of the first match with a line of context
+// on either side, marking the matches on it.
+func newSnippet(content string, ranges [][2]int) snippet {
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	first := strings.Count(content[:ranges[0][0]], "\n")
+	lineStart := strings.LastIndexByte(content[:ranges[0][0]], '\n') + 1
+
+	marked := strings.Builder{}
+	pos := lineStart
+	lineEnd := lineStart + len(lines[first])
+	for _, r := range ranges {
+		if r[0] < pos || r[1] > lineEnd {
+			continue
+		}
+		marked.WriteString(content[pos:r[0]])
+		marked.WriteString("<mark>" + content[r[0]:r[1]] + "</mark>")
+		pos = r[1]
+	}
+	marked.WriteString(content[pos:lineEnd])
+
+	start := first - 1
+	if start < 0 {
+		start = 0
+	}
+	end := first + 2
+	if end > len(lines) {
+		end = len(lines)
+	}
+	sn := snippet{
+		Lines:     append([]string{}, lines[start:end]...),
+		StartLine: start + 1,
+	}
+	sn.Lines[first-start] = marked.String()
+	return sn
+}
+
+// facets counts results by language and repository.
+func facets(results []result) []facetGroup {
+	languages := map[string]int{}
+	repos := map[string]int{}
+	for _, r := range results {
+		if r.Language != "" {
+			languages[r.Language]++
+		}
+		repos[r.RepoName]++
+	}
+
+	lg := facetGroup{Kind: "Languages", Facets: []facet{}}
+	for name, n := range languages {
+		lg.Facets = append(lg.Facets, facet{Name: name, Query: "language:" + quoteValue(name), Occurrences: n})
+	}
+	rg := facetGroup{Kind: "Repositories", Facets: []facet{}}
+	for name, n := range repos {
+		owner, _, _ := strings.Cut(name, "/")
+		rg.Facets = append(rg.Facets, facet{Name: name, Owner: owner, Query: "repo:" + name, Occurrences: n})
+	}
+	for _, g := range []facetGroup{lg, rg} {
+		sort.Slice(g.Facets, func(i, j int) bool {
+			if g.Facets[i].Occurrences != g.Facets[j].Occurrences {
+				return g.Facets[i].Occurrences > g.Facets[j].Occurrences
+			}
+			return g.Facets[i].Name < g.Facets[j].Name
+		})
+	}
+	return []facetGroup{lg, rg}
+}
+
+func quoteValue(v string) string {
+	if strings.ContainsAny(v, " \t") {
+		return strconv.Quote(v)
+	}
+	return v
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,193 @@
+package fake_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/abergmeier/knollledge/internal/fake"
+	"github.com/abergmeier/knollledge/internal/github"
+	"github.com/google/go-cmp/cmp"
+	gh "github.com/google/go-github/v52/github"
+)
+
+func newClient(t *testing.T, srv *fake.Server) github.Client {
+	t.Cleanup(srv.Close)
+	c := github.NewClient(nil)
+	c.CodeSearchURL, _ = url.Parse(srv.CodeSearchURL())
+	c.RetryPolicy = &github.RetryPolicy{
+		MaxAttempts:          3,
+		InitialBackoff:       time.Millisecond,
+		MaxBackoff:           time.Millisecond,
+		Multiplier:           1,
+		AcceptedPollInterval: time.Millisecond,
+		Retry:                github.RetryAll,
+	}
+	return c
+}
+
+func TestSearch(t *testing.T) {
+	srv := fake.NewServer(fake.DefaultCorpus())
+	srv.PageSize = 2
+	c := newClient(t, srv)
+
+	pages := []uint{}
+	paths := []string{}
+	it := c.CodeSearch().Pages(context.TODO(), "path:**.java class")
+	for it.Next() {
+		p := it.Page()
+		pages = append(pages, p.PageNumber)
+		if p.TotalPages != 3 || p.ResultsCount != 6 {
+			t.Errorf("Unexpected totals: %d pages, %d results", p.TotalPages, p.ResultsCount)
+		}
+		for _, r := range p.Results {
+			paths = append(paths, r.RepoName+"/"+r.Path)
+		}
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal("Search failed:", err)
+	}
+
+	if diff := cmp.Diff(pages, []uint{1, 2, 3}); diff != "" {
+		t.Errorf("Unexpected pages:\n%s\n", diff)
+	}
+	if diff := cmp.Diff(paths, []string{
+		"acme/widgets/src/main/java/com/acme/Assembly.java",
+		"acme/widgets/src/main/java/com/acme/Gadget.java",
+		"acme/widgets/src/main/java/com/acme/Widget.java",
+		"initech/tps/src/tps/CoverSheet.java",
+		"initech/tps/src/tps/Report.java",
+		"initech/tps/src/tps/Stapler.java",
+	}); diff != "" {
+		t.Errorf("Unexpected results:\n%s\n", diff)
+	}
+}
+
+func TestSearchResult(t *testing.T) {
+	srv := fake.NewServer(fake.DefaultCorpus())
+	c := newClient(t, srv)
+
+	res, resp, err := c.CodeSearch().Search(context.TODO(), "language:java symbol:Widget", &github.SearchOptions{})
+	if err != nil {
+		t.Fatal("Search failed:", err)
+	}
+	if resp.Header.Get("X-GitHub-Request-Id") != res.RequestId || res.RequestId == "" {
+		t.Error("Unexpected request id:", res.RequestId)
+	}
+
+	diff := cmp.Diff(res.Results, []*github.CodeResult{
+		{
+			Path:     "src/main/java/com/acme/Widget.java",
+			RefName:  "refs/heads/main",
+			Language: "Java",
+			RepoName: "acme/widgets",
+			Snippets: []*github.Snippet{
+				{
+					Lines:     []string{"", "public class <mark>Widget</mark> {", "    private final String name;"},
+					StartLine: 2,
+				},
+			},
+			MatchCount: 1,
+			Matches:    []*github.Match{{Start: 32, End: 38}},
+		},
+	}, cmp.FilterPath(func(p cmp.Path) bool {
+		switch p.Last().String() {
+		case ".Sha", ".CommitSha", ".RepoId":
+			return true
+		}
+		return false
+	}, cmp.Ignore()))
+	if diff != "" {
+		t.Errorf("Unexpected results:\n%s\n", diff)
+	}
+
+	if diff := cmp.Diff(res.Facets, []*github.FacetGroup{
+		{Kind: github.FacetKindLanguages, Facets: []*github.Facet{{Name: "Java", Query: "language:Java", Occurrences: 1}}},
+		{Kind: github.FacetKindRepositories, Facets: []*github.Facet{{Name: "acme/widgets", Owner: "acme", Query: "repo:acme/widgets", Occurrences: 1}}},
+	}); diff != "" {
+		t.Errorf("Unexpected facets:\n%s\n", diff)
+	}
+}
+
+func TestQueryErrors(t *testing.T) {
+	c := newClient(t, fake.NewServer(fake.DefaultCorpus()))
+
+	_, _, err := c.CodeSearch().Search(context.TODO(), "(path:**/go.mod OR", &github.SearchOptions{})
+	qerr := &github.QueryError{}
+	if !errors.As(err, &qerr) {
+		t.Fatalf("Expected QueryError, got %T: %v", err, err)
+	}
+	if len(qerr.Details) != 1 || qerr.Details[0].Position != 18 {
+		t.Errorf("Unexpected details: %s", qerr)
+	}
+}
+
+func TestInvalidPageToken(t *testing.T) {
+	c := newClient(t, fake.NewServer(fake.DefaultCorpus()))
+
+	_, _, err := c.CodeSearch().Search(context.TODO(), "class", &github.SearchOptions{
+		ListOptions: github.ListOptions{Page: 2, PageToken: "forged"},
+	})
+	eresp := &gh.ErrorResponse{}
+	if !errors.As(err, &eresp) || eresp.Message != "invalid page token" {
+		t.Errorf("Expected invalid page token, got %T: %v", err, err)
+	}
+}
+
+func TestFaults(t *testing.T) {
+	srv := fake.NewServer(fake.DefaultCorpus())
+	srv.RetryAfter = 0
+	c := newClient(t, srv)
+
+	srv.Fail(fake.FaultAccepted, fake.FaultSecondaryRateLimit)
+	res, _, err := c.CodeSearch().Search(context.TODO(), "path:**/go.mod", &github.SearchOptions{})
+	if err != nil {
+		t.Fatal("Search failed despite retries:", err)
+	}
+	if res.ResultsCount != 2 || len(srv.Queries()) != 3 {
+		t.Errorf("Unexpected result after %d requests: %d results", len(srv.Queries()), res.ResultsCount)
+	}
+
+	srv.Fail(fake.FaultServerError, fake.FaultServerError, fake.FaultServerError)
+	_, _, err = c.CodeSearch().Search(context.TODO(), "path:**/go.mod", &github.SearchOptions{})
+	eresp := &gh.ErrorResponse{}
+	if !errors.As(err, &eresp) || eresp.Response.StatusCode != 502 {
+		t.Errorf("Expected 502, got %T: %v", err, err)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	srv := fake.NewServer(fake.DefaultCorpus())
+	srv.RateLimit = 2
+	c := newClient(t, srv)
+
+	for i := 0; i < 2; i++ {
+		_, resp, err := c.CodeSearch().Search(context.TODO(), "path:**/go.mod", &github.SearchOptions{})
+		if err != nil {
+			t.Fatal("Search failed:", err)
+		}
+		if resp.Rate.Limit != 2 || resp.Rate.Remaining != 1-i {
+			t.Error("Unexpected rate:", resp.Rate)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	_, _, err := c.CodeSearch().Search(ctx, "path:**/go.mod", &github.SearchOptions{})
+	werr := &github.RateLimitWaitError{}
+	if !errors.As(err, &werr) || werr.Secondary {
+		t.Fatalf("Expected to wait for the primary rate limit, got %T: %v", err, err)
+	}
+
+	resp, err := http.Get(srv.CodeSearchURL() + "search?q=foo")
+	if err != nil {
+		t.Fatal("Get failed:", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden || resp.Header.Get("X-RateLimit-Remaining") != "0" {
+		t.Errorf("Expected rate limited response, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,298 @@
+package github
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultAppBaseURL = "https://api.github.com/"
+
+// Authenticator adds credentials to requests created by the client.
+type Authenticator interface {
+	Authenticate(req *http.Request) error
+}
+
+// CookieAuthenticator authenticates requests with the cookies of a
+// logged in browser session. This is what cs.github.com accepts.
+//
+// Cookies are scoped like a browser would: a cookie is only sent if its
+// Domain and Path match the request, it has not expired and, if Secure,
+// the request uses https. Cookies without a Domain, as given in a Cookie
+// header, are taken to be cookies of github.com. Hosts sharing the login
+// session of github.com also get its cookies, no other host gets them.
+type CookieAuthenticator struct {
+	Cookies []*http.Cookie
+}
+
+// sessionDomain is the host of the login session. Cookies without a
+// Domain belong to it.
+const sessionDomain = "github.com"
+
+// sessionHosts maps hosts to the host whose login session they accept.
+// Browsers store the session cookies of github.com for github.com only,
+// but cs.github.com needs them.
+var sessionHosts = map[string]string{
+	"cs.github.com": sessionDomain,
+}
+
+func (a *CookieAuthenticator) Authenticate(req *http.Request) error {
+	host := strings.ToLower(req.URL.Hostname())
+	sent := a.addCookies(req, host, nil)
+	if shared, ok := sessionHosts[host]; ok {
+		a.addCookies(req, shared, sent)
+	}
+	return nil
+}
+
+// addCookies adds the cookies for host to req, except those named in
+// skip. It returns the names of the cookies added.
+func (a *CookieAuthenticator) addCookies(req *http.Request, host string, skip map[string]bool) map[string]bool {
+	now := time.Now()
+	added := map[string]bool{}
+	for _, c := range a.Cookies {
+		if skip[c.Name] {
+			continue
+		}
+		if !c.Expires.IsZero() && c.Expires.Before(now) {
+			continue
+		}
+		if c.Secure && req.URL.Scheme != "https" {
+			continue
+		}
+		if !cookieDomainMatch(host, c.Domain) || !cookiePathMatch(req.URL.Path, c.Path) {
+			continue
+		}
+		req.AddCookie(&http.Cookie{Name: c.Name, Value: c.Value})
+		added[c.Name] = true
+	}
+	return added
+}
+
+// cookieDomainMatch implements the domain matching of RFC 6265. A leading
+// dot marks cookies which also apply to subdomains. An empty domain is
+// sessionDomain.
+func cookieDomainMatch(host, domain string) bool {
+	if domain == "" {
+		domain = sessionDomain
+	}
+	host = strings.ToLower(host)
+	domain = strings.ToLower(domain)
+	if !strings.HasPrefix(domain, ".") {
+		return host == domain
+	}
+	return host == domain[1:] || strings.HasSuffix(host, domain)
+}
+
+func cookiePathMatch(path, cookiePath string) bool {
+	if cookiePath == "" || cookiePath == "/" || path == cookiePath {
+		return true
+	}
+	if !strings.HasPrefix(path, cookiePath) {
+		return false
+	}
+	return strings.HasSuffix(cookiePath, "/") || path[len(cookiePath)] == '/'
+}
+
+// TokenAuthenticator authenticates requests with a personal access token
+// or any other bearer token.
+type TokenAuthenticator struct {
+	Token string
+}
+
+func (a *TokenAuthenticator) Authenticate(req *http.Request) error {
+	req.Header.Set("Authorization", "Bearer "+a.Token)
+	return nil
+}
+
+// AppAuthenticator authenticates requests as a GitHub App installation.
+// It signs a JWT with the App's private key, exchanges it for an
+// installation token and renews the token shortly before it expires.
+type AppAuthenticator struct {
+	AppID          int64
+	InstallationID int64
+	PrivateKey     *rsa.PrivateKey
+
+	// BaseURL of the REST API issuing installation tokens. Defaults to
+	// https://api.github.com/.
+	BaseURL string
+	// Client used for token requests. Defaults to http.DefaultClient.
+	Client *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// NewAppAuthenticator creates an AppAuthenticator from a PEM encoded
+// private key as downloaded from the App settings.
+func NewAppAuthenticator(appID, installationID int64, privateKeyPEM []byte) (*AppAuthenticator, error) {
+	block, _ := pem.Decode(privateKeyPEM)
+	if block == nil {
+		return nil, errors.New("private key is not PEM encoded")
+	}
+
+	var key *rsa.PrivateKey
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		k, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		key = k
+	case "PRIVATE KEY":
+		k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		rk, ok := k.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("private key is %T, not RSA", k)
+		}
+		key = rk
+	default:
+		return nil, fmt.Errorf("unsupported private key type %q", block.Type)
+	}
+
+	return &AppAuthenticator{
+		AppID:          appID,
+		InstallationID: installationID,
+		PrivateKey:     key,
+	}, nil
+}
+
+func (a *AppAuthenticator) Authenticate(req *http.Request) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token == "" || time.Until(a.expires) < time.Minute {
+		err := a.refresh(req)
+		if err != nil {
+			return fmt.Errorf("fetching installation token of app %d: %w", a.AppID, err)
+		}
+	}
+	req.Header.Set("Authorization", "Bearer "+a.token)
+	return nil
+}
+
+func (a *AppAuthenticator) refresh(req *http.Request) error {
+	jwt, err := a.jwt(time.Now())
+	if err != nil {
+		return err
+	}
+
+	baseURL := a.BaseURL
+	if baseURL == "" {
+		baseURL = defaultAppBaseURL
+	}
+	u := fmt.Sprintf("%s/app/installations/%d/access_tokens", strings.TrimSuffix(baseURL, "/"), a.InstallationID)
+	tokenReq, err := http.NewRequestWithContext(req.Context(), "POST", u, nil)
+	if err != nil {
+		return err
+	}
+	tokenReq.Header.Set("Accept", "application/vnd.github+json")
+	tokenReq.Header.Set("Authorization", "Bearer "+jwt)
+
+	c := a.Client
+	if c == nil {
+		c = http.DefaultClient
+	}
+	resp, err := c.Do(tokenReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := CheckResponse(resp); err != nil {
+		return err
+	}
+
+	token := struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return err
+	}
+	if token.Token == "" {
+		return errors.New("response contains no token")
+	}
+
+	a.token = token.Token
+	a.expires = token.ExpiresAt
+	return nil
+}
+
+// jwt returns the RS256 signed JSON Web Token identifying the App.
+func (a *AppAuthenticator) jwt(now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{
+		"alg": "RS256",
+		"typ": "JWT",
+	})
+	if err != nil {
+		return "", err
+	}
+	// Backdate issuance to allow for clock drift, GitHub rejects
+	// expirations more than 10 minutes in the future.
+	claims, err := json.Marshal(map[string]int64{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": a.AppID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	buf := bytes.Buffer{}
+	buf.WriteString(base64.RawURLEncoding.EncodeToString(header))
+	buf.WriteByte('.')
+	buf.WriteString(base64.RawURLEncoding.EncodeToString(claims))
+
+	digest := sha256.Sum256(buf.Bytes())
+	sig, err := rsa.SignPKCS1v15(rand.Reader, a.PrivateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	buf.WriteByte('.')
+	buf.WriteString(base64.RawURLEncoding.EncodeToString(sig))
+	return buf.String(), nil
+}
+
+// AuthTransport is an http.RoundTripper which authenticates requests with
+// an Authenticator. It makes authenticators usable with HTTP clients not
+// created by NewClient, e.g. the one of go-github.
+type AuthTransport struct {
+	Authenticator Authenticator
+	// Base sends the authenticated requests. Defaults to
+	// http.DefaultTransport.
+	Base http.RoundTripper
+}
+
+func (t *AuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTrippers must not modify the request they are given.
+	req = req.Clone(req.Context())
+	if err := t.Authenticator.Authenticate(req); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
+}
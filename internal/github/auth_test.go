@@ -0,0 +1,169 @@
+package github
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/abergmeier/knollledge/internal/cookie"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCookieAuthenticator(t *testing.T) {
+	a := &CookieAuthenticator{
+		Cookies: []*http.Cookie{
+			{Name: "user_session", Value: "abc", Domain: "cs.github.com", Secure: true},
+			{Name: "logged_in", Value: "yes", Domain: ".github.com", Path: "/"},
+			{Name: "_gh_sess", Value: "a=b"},
+			{Name: "other", Value: "x", Domain: "github.com"},
+			{Name: "expired", Value: "x", Expires: time.Unix(1000000000, 0)},
+			{Name: "scoped", Value: "x", Path: "/api/"},
+			{Name: "unrelated", Value: "x", Path: "/apis"},
+		},
+	}
+
+	tests := map[string]string{
+		"https://cs.github.com/api/search": "user_session=abc; logged_in=yes; _gh_sess=a=b; other=x; scoped=x",
+		"http://cs.github.com/search":      "logged_in=yes; _gh_sess=a=b; other=x",
+		"https://github.com/login":         "logged_in=yes; _gh_sess=a=b; other=x",
+		"https://api.github.com/search":    "logged_in=yes",
+		"https://example.com/api":          "",
+		"http://127.0.0.1:8080/api/search": "",
+	}
+	for u, expected := range tests {
+		req, _ := http.NewRequest("GET", u, nil)
+		a.Authenticate(req)
+		if h := req.Header.Get("Cookie"); h != expected {
+			t.Errorf("Unexpected Cookie header for %s:\n%s\nexpected:\n%s", u, h, expected)
+		}
+	}
+}
+
+func TestCookieAuthenticatorExport(t *testing.T) {
+	cookies, err := cookie.Load("testdata/cookies.txt")
+	if err != nil {
+		t.Fatal("Loading cookies failed:", err)
+	}
+	a := &CookieAuthenticator{Cookies: cookies}
+
+	req, _ := http.NewRequest("GET", "https://cs.github.com/api/search?q=class", nil)
+	a.Authenticate(req)
+	names := []string{}
+	for _, c := range req.Cookies() {
+		names = append(names, c.Name)
+	}
+	diff := cmp.Diff(names, []string{
+		"_octo",
+		"logged_in",
+		"dotcom_user",
+		"user_session",
+		"__Host-user_session_same_site",
+		"_gh_sess",
+		"tz",
+	})
+	if diff != "" {
+		t.Errorf("Unexpected cookies sent to cs.github.com:\n%s", diff)
+	}
+}
+
+func TestTokenAuthenticator(t *testing.T) {
+	c := NewClient(nil)
+	c.Authenticator = &TokenAuthenticator{Token: "ghp_123"}
+	req, err := c.NewRequest("GET", "search", nil)
+	if err != nil {
+		t.Fatal("NewRequest failed:", err)
+	}
+	if h := req.Header.Get("Authorization"); h != "Bearer ghp_123" {
+		t.Error("Unexpected Authorization header:", h)
+	}
+}
+
+func TestAppAuthenticator(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	})
+
+	var tokenRequests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&tokenRequests, 1)
+		if r.Method != "POST" || r.URL.Path != "/app/installations/42/access_tokens" {
+			t.Errorf("Unexpected token request: %s %s", r.Method, r.URL.Path)
+		}
+		jwt := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if err := verifyJWT(jwt, &key.PublicKey, 7); err != nil {
+			t.Error("Invalid JWT:", err)
+		}
+		fmt.Fprintf(w, `{"token": "ghs_abc", "expires_at": %q}`, time.Now().Add(time.Hour).Format(time.RFC3339))
+	}))
+	defer srv.Close()
+
+	a, err := NewAppAuthenticator(7, 42, keyPEM)
+	if err != nil {
+		t.Fatal("NewAppAuthenticator failed:", err)
+	}
+	a.BaseURL = srv.URL
+
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest("GET", "https://cs.github.com/api/search", nil)
+		if err := a.Authenticate(req); err != nil {
+			t.Fatal("Authenticate failed:", err)
+		}
+		if h := req.Header.Get("Authorization"); h != "Bearer ghs_abc" {
+			t.Error("Unexpected Authorization header:", h)
+		}
+	}
+	if n := atomic.LoadInt32(&tokenRequests); n != 1 {
+		t.Error("Installation token was not reused, token requests:", n)
+	}
+}
+
+func verifyJWT(jwt string, key *rsa.PublicKey, appID int64) error {
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("expected 3 parts, got %d", len(parts))
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+		return err
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return err
+	}
+	claims := struct {
+		Iss int64 `json:"iss"`
+		Exp int64 `json:"exp"`
+	}{}
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return err
+	}
+	if claims.Iss != appID {
+		return fmt.Errorf("unexpected issuer %d", claims.Iss)
+	}
+	if time.Unix(claims.Exp, 0).Before(time.Now()) {
+		return fmt.Errorf("token already expired")
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package github
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrCacheMiss is returned in offline mode for requests whose response is
+// not cached.
+var ErrCacheMiss = errors.New("response not cached")
+
+// Headers which are not cached. Served from the cache, rate limit
+// headers would overwrite the current rate limit state of the client.
+var uncachedHeaders = []string{
+	"Set-Cookie",
+	headerRateLimit,
+	headerRateRemaining,
+	headerRateReset,
+	"X-RateLimit-Used",
+	"X-RateLimit-Resource",
+}
+
+// Cache stores successful responses to GET requests in Dir, keyed by
+// method and URL, query parameters included. Searches which failed or
+// reported query errors are not successful, although answered with 200.
+// Entries are shared by all credentials.
+//
+// A cached response younger than TTL is served without asking the
+// server. Older ones are revalidated with If-None-Match or
+// If-Modified-Since if the server sent an ETag or Last-Modified, and
+// fetched again otherwise.
+type Cache struct {
+	Dir string
+	TTL time.Duration
+	// Offline serves every request from the cache, regardless of TTL,
+	// and fails with ErrCacheMiss instead of sending a request.
+	Offline bool
+}
+
+// cacheEntry is the file a response is cached in.
+type cacheEntry struct {
+	Method   string           `json:"method"`
+	URL      string           `json:"url"`
+	StoredAt time.Time        `json:"stored_at"`
+	Response RecordedResponse `json:"response"`
+}
+
+// do serves req from the cache or sends it with send.
+func (c *Cache) do(req *http.Request, send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	u := cassetteURL(req.URL)
+	if req.Method != http.MethodGet {
+		if c.Offline {
+			return nil, fmt.Errorf("offline: %s %s: %w", req.Method, u, ErrCacheMiss)
+		}
+		return send(req)
+	}
+
+	p := c.path(req.Method, u)
+	e, err := c.load(p)
+	if err != nil {
+		log.Printf("Ignoring cached response to %s %s: %s\n", req.Method, u, err)
+	}
+	if c.Offline {
+		if e == nil {
+			return nil, fmt.Errorf("offline: %s %s: %w", req.Method, u, ErrCacheMiss)
+		}
+		return e.Response.response(req), nil
+	}
+	if e != nil && time.Since(e.StoredAt) < c.TTL {
+		return e.Response.response(req), nil
+	}
+
+	sent := req
+	if e != nil {
+		etag := e.Response.Header.Get("ETag")
+		modified := e.Response.Header.Get("Last-Modified")
+		if etag != "" || modified != "" {
+			sent = req.Clone(req.Context())
+			if etag != "" {
+				sent.Header.Set("If-None-Match", etag)
+			}
+			if modified != "" {
+				sent.Header.Set("If-Modified-Since", modified)
+			}
+		}
+	}
+
+	resp, err := send(sent)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotModified && e != nil:
+		resp.Body.Close()
+		e.StoredAt = time.Now().UTC()
+		if err := c.store(p, e); err != nil {
+			log.Printf("Caching response to %s %s failed: %s\n", req.Method, u, err)
+		}
+		cached := e.Response.response(req)
+		// Keep the rate limit state of the revalidation.
+		for _, h := range uncachedHeaders {
+			if v := resp.Header.Values(h); len(v) != 0 {
+				cached.Header[http.CanonicalHeaderKey(h)] = v
+			}
+		}
+		return cached, nil
+	case resp.StatusCode == http.StatusOK && checkSession(resp) == nil:
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		if !succeeded(body) {
+			break
+		}
+		err = c.store(p, &cacheEntry{
+			Method:   req.Method,
+			URL:      u,
+			StoredAt: time.Now().UTC(),
+			Response: recordResponse(resp, body, uncachedHeaders),
+		})
+		if err != nil {
+			log.Printf("Caching response to %s %s failed: %s\n", req.Method, u, err)
+		}
+	}
+	return resp, nil
+}
+
+// succeeded reports whether body is the result of a search which neither
+// failed nor reported query errors.
+func succeeded(body []byte) bool {
+	result := struct {
+		Failed      bool              `json:"failed"`
+		QueryErrors []json.RawMessage `json:"query_errors"`
+	}{}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return false
+	}
+	return !result.Failed && len(result.QueryErrors) == 0
+}
+
+// path returns the file the response to method u is cached in.
+func (c *Cache) path(method, u string) string {
+	return filepath.Join(c.Dir, fmt.Sprintf("%x.json", sha256.Sum256([]byte(method+" "+u))))
+}
+
+// load reads the entry at p. A missing entry is not an error.
+func (c *Cache) load(p string) (*cacheEntry, error) {
+	data, err := os.ReadFile(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	e := &cacheEntry{}
+	err = json.Unmarshal(data, e)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// store writes e to p atomically, so concurrent jobs never read a partial
+// entry.
+func (c *Cache) store(p string, e *cacheEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(p), 0755)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), filepath.Base(p)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
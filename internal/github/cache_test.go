@@ -0,0 +1,182 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newCacheTestClient returns a client caching in a fresh directory. The
+// server answers with the number of requests it got so far, and with
+// etag as ETag if set.
+func newCacheTestClient(t *testing.T, etag string) (*client, *int32, *[]string) {
+	var hits int32
+	conditional := []string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&hits, 1)
+		w.Header().Set(headerRateRemaining, fmt.Sprint(100-n))
+		if etag != "" {
+			w.Header().Set("ETag", etag)
+			if inm := r.Header.Get("If-None-Match"); inm != "" {
+				conditional = append(conditional, inm)
+				if inm == etag {
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"results_count":%d,"page_number":1}`, n)
+	}))
+	t.Cleanup(srv.Close)
+
+	c := NewClient(nil)
+	c.CodeSearchURL, _ = url.Parse(srv.URL + "/api/")
+	c.RetryPolicy = nil
+	c.Cache = &Cache{Dir: filepath.Join(t.TempDir(), "cache")}
+	return c, &hits, &conditional
+}
+
+func cachedSearch(t *testing.T, c *client, q string) (*CodeSearchResult, error) {
+	t.Helper()
+	result, _, err := c.CodeSearch().Search(context.TODO(), q, &SearchOptions{})
+	return result, err
+}
+
+func TestCacheTTL(t *testing.T) {
+	c, hits, _ := newCacheTestClient(t, "")
+	c.Cache.TTL = 1 << 40
+	// Cached responses do not count against the budget.
+	c.Limiter = &Limiter{Budget: 2}
+
+	for i := 0; i < 3; i++ {
+		result, err := cachedSearch(t, c, "class")
+		if err != nil {
+			t.Fatal("Search failed:", err)
+		}
+		if result.ResultsCount != 1 {
+			t.Errorf("Expected cached response, got response %d", result.ResultsCount)
+		}
+	}
+	result, err := cachedSearch(t, c, "other")
+	if err != nil {
+		t.Fatal("Search failed:", err)
+	}
+	if result.ResultsCount != 2 || *hits != 2 {
+		t.Errorf("Expected a different query to miss the cache, got response %d after %d requests", result.ResultsCount, *hits)
+	}
+
+	entries, err := os.ReadDir(c.Cache.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		data, err := os.ReadFile(filepath.Join(c.Cache.Dir, e.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(strings.ToLower(string(data)), strings.ToLower(headerRateRemaining)) {
+			t.Errorf("Rate limit state was cached:\n%s", data)
+		}
+	}
+}
+
+func TestCacheRevalidate(t *testing.T) {
+	c, hits, conditional := newCacheTestClient(t, `"v1"`)
+	for i := 0; i < 2; i++ {
+		result, resp, err := c.CodeSearch().Search(context.TODO(), "class", &SearchOptions{})
+		if err != nil {
+			t.Fatal("Search failed:", err)
+		}
+		if result.ResultsCount != 1 {
+			t.Errorf("Expected revalidated response, got response %d", result.ResultsCount)
+		}
+		if remaining := 100 - int(*hits); resp.Rate.Remaining != remaining {
+			t.Errorf("Expected rate state of the last request, got %s", resp.Rate)
+		}
+	}
+	if *hits != 2 || len(*conditional) != 1 || (*conditional)[0] != `"v1"` {
+		t.Errorf("Expected one conditional request, got %d requests with If-None-Match %q", *hits, *conditional)
+	}
+}
+
+func TestCacheWithoutValidators(t *testing.T) {
+	c, hits, _ := newCacheTestClient(t, "")
+	for i := 1; i <= 2; i++ {
+		result, err := cachedSearch(t, c, "class")
+		if err != nil {
+			t.Fatal("Search failed:", err)
+		}
+		if result.ResultsCount != uint64(i) {
+			t.Errorf("Expected expired response to be fetched again, got response %d", result.ResultsCount)
+		}
+	}
+	if *hits != 2 {
+		t.Errorf("Expected 2 requests, got %d", *hits)
+	}
+}
+
+func TestCacheOffline(t *testing.T) {
+	c, hits, _ := newCacheTestClient(t, "")
+	c.Cache.Offline = true
+	_, err := cachedSearch(t, c, "class")
+	if !errors.Is(err, ErrCacheMiss) {
+		t.Fatalf("Expected ErrCacheMiss, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "q=class") {
+		t.Errorf("Expected error to name the request, got %v", err)
+	}
+
+	c.Cache.Offline = false
+	_, err = cachedSearch(t, c, "class")
+	if err != nil {
+		t.Fatal("Search failed:", err)
+	}
+
+	// Offline serves responses regardless of their age.
+	c.Cache.Offline = true
+	result, err := cachedSearch(t, c, "class")
+	if err != nil {
+		t.Fatal("Offline search failed:", err)
+	}
+	if result.ResultsCount != 1 || *hits != 1 {
+		t.Errorf("Expected cached response without a request, got response %d after %d requests", result.ResultsCount, *hits)
+	}
+}
+
+func TestCacheFailedSearch(t *testing.T) {
+	for _, body := range []string{
+		`{"failed":true,"page_number":1}`,
+		`{"query_errors":[{"message":"unbalanced parentheses","position":0}],"page_number":1}`,
+	} {
+		var hits int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&hits, 1)
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, body)
+		}))
+		defer srv.Close()
+
+		c := NewClient(nil)
+		c.CodeSearchURL, _ = url.Parse(srv.URL + "/api/")
+		c.RetryPolicy = nil
+		c.Cache = &Cache{Dir: filepath.Join(t.TempDir(), "cache"), TTL: time.Hour}
+		for i := 0; i < 2; i++ {
+			if _, err := cachedSearch(t, c, "(class"); err == nil {
+				t.Fatalf("Expected %s to fail the search", body)
+			}
+		}
+		if hits != 2 {
+			t.Errorf("Expected %s not to be cached, got %d requests", body, hits)
+		}
+	}
+}
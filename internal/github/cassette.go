@@ -0,0 +1,207 @@
+package github
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+)
+
+// RecordEnv is the environment variable which, if set, makes test clients
+// record live exchanges with cs.github.com instead of replaying them.
+const RecordEnv = "KNOLLLEDGE_RECORD"
+
+// Headers which carry credentials. They are never written to a cassette.
+var (
+	sensitiveRequestHeaders  = []string{"Authorization", "Cookie", headerOTP}
+	sensitiveResponseHeaders = []string{"Set-Cookie"}
+)
+
+// Cassette is a sequence of recorded HTTP exchanges.
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// Interaction is a single recorded exchange.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is a request with credentials removed.
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+}
+
+// RecordedResponse is a response with credentials removed. JSON bodies
+// are kept as is to keep cassettes readable, any other body is kept in
+// Text.
+type RecordedResponse struct {
+	StatusCode int             `json:"status_code"`
+	Header     http.Header     `json:"header,omitempty"`
+	Body       json.RawMessage `json:"body,omitempty"`
+	Text       string          `json:"text,omitempty"`
+}
+
+// LoadCassette reads the cassette stored at path.
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &Cassette{}
+	err = json.Unmarshal(data, c)
+	if err != nil {
+		return nil, fmt.Errorf("cassette %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Save writes c to path.
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// CassetteTransport is an http.RoundTripper which either records the
+// exchanges of Transport to Cassette or replays them from it.
+//
+// Replayed requests are matched by method and URL, each recorded
+// interaction is replayed once and in order.
+type CassetteTransport struct {
+	Cassette *Cassette
+
+	// Record makes requests go to Transport and appends them to Cassette.
+	Record bool
+	// Transport performs requests when recording. Nil uses
+	// http.DefaultTransport.
+	Transport http.RoundTripper
+
+	mu   sync.Mutex
+	used map[*Interaction]bool
+}
+
+func (t *CassetteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.Record {
+		return t.record(req)
+	}
+	return t.replay(req)
+}
+
+func (t *CassetteTransport) record(req *http.Request) (*http.Response, error) {
+	transport := t.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	i := &Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			URL:    cassetteURL(req.URL),
+			Header: withoutHeaders(req.Header, sensitiveRequestHeaders),
+		},
+		Response: recordResponse(resp, body, sensitiveResponseHeaders),
+	}
+
+	t.mu.Lock()
+	t.Cassette.Interactions = append(t.Cassette.Interactions, i)
+	t.mu.Unlock()
+	return resp, nil
+}
+
+func (t *CassetteTransport) replay(req *http.Request) (*http.Response, error) {
+	u := cassetteURL(req.URL)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.used == nil {
+		t.used = map[*Interaction]bool{}
+	}
+	for _, i := range t.Cassette.Interactions {
+		if t.used[i] || i.Request.Method != req.Method || i.Request.URL != u {
+			continue
+		}
+		t.used[i] = true
+		return i.Response.response(req), nil
+	}
+	return nil, fmt.Errorf("cassette: no recorded interaction left for %s %s", req.Method, u)
+}
+
+// recordResponse records resp with the body body, without the headers
+// in drop.
+func recordResponse(resp *http.Response, body []byte, drop []string) RecordedResponse {
+	r := RecordedResponse{
+		StatusCode: resp.StatusCode,
+		Header:     withoutHeaders(resp.Header, drop),
+	}
+	if json.Valid(body) {
+		r.Body = body
+	} else {
+		r.Text = string(body)
+	}
+	return r
+}
+
+// response returns r as the response to req.
+func (r *RecordedResponse) response(req *http.Request) *http.Response {
+	body := []byte(r.Text)
+	if len(r.Body) != 0 {
+		body = r.Body
+	}
+	header := r.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.StatusCode, http.StatusText(r.StatusCode)),
+		StatusCode:    r.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// cassetteURL returns the sanitized form of u requests are recorded and
+// matched by. Query parameters are sorted.
+func cassetteURL(u *url.URL) string {
+	c := *u
+	c.User = nil
+	c.RawQuery = c.Query().Encode()
+	return sanitizeURL(&c).String()
+}
+
+// withoutHeaders returns a copy of h without the headers in names.
+func withoutHeaders(h http.Header, names []string) http.Header {
+	h = h.Clone()
+	for _, name := range names {
+		h.Del(name)
+	}
+	if len(h) == 0 {
+		return nil
+	}
+	return h
+}
@@ -0,0 +1,109 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCassette(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := r.Cookie("user_session"); err != nil {
+			t.Error("Request without session cookie")
+		}
+		http.SetCookie(w, &http.Cookie{Name: "user_session", Value: "renewed-secret"})
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set(headerRateRemaining, "9")
+		fmt.Fprintf(w, `{"results_count":%d,"page_number":1}`, len(r.URL.Query().Get("q")))
+	}))
+	defer srv.Close()
+
+	search := func(c *client, q string) *CodeSearchResult {
+		t.Helper()
+		req, err := c.NewRequest("GET", "search?q="+url.QueryEscape(q)+"&access_token=token-secret", nil)
+		if err != nil {
+			t.Fatal("NewRequest failed:", err)
+		}
+		result := new(CodeSearchResult)
+		_, err = c.Do(context.TODO(), req, result)
+		if err != nil {
+			t.Fatal("Do failed:", err)
+		}
+		return result
+	}
+
+	recorder := &CassetteTransport{Cassette: &Cassette{}, Record: true}
+	c := NewClient(&http.Client{Transport: recorder})
+	c.CodeSearchURL, _ = url.Parse(srv.URL + "/api/")
+	c.Authenticator = &CookieAuthenticator{Cookies: []*http.Cookie{{Name: "user_session", Value: "cookie-secret", Domain: c.CodeSearchURL.Hostname()}}}
+	recorded := []*CodeSearchResult{search(c, "a"), search(c, "bb")}
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	err := recorder.Cassette.Save(path)
+	if err != nil {
+		t.Fatal("Save failed:", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"cookie-secret", "renewed-secret", "token-secret"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("Cassette contains %q:\n%s", secret, data)
+		}
+	}
+
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		t.Fatal("LoadCassette failed:", err)
+	}
+	if remaining := cassette.Interactions[0].Response.Header.Get(headerRateRemaining); remaining != "9" {
+		t.Errorf("Expected rate limit header to be kept, got %q", remaining)
+	}
+
+	// Replay in a different order and without credentials.
+	c = NewClient(&http.Client{Transport: &CassetteTransport{Cassette: cassette}})
+	c.CodeSearchURL, _ = url.Parse(srv.URL + "/api/")
+	srv.Close()
+	replayed := []*CodeSearchResult{nil, search(c, "bb")}
+	replayed[0] = search(c, "a")
+	if diff := cmp.Diff(recorded, replayed); diff != "" {
+		t.Errorf("Unexpected replay:\n%s", diff)
+	}
+
+	req, err := c.NewRequest("GET", "search?q=a", nil)
+	if err != nil {
+		t.Fatal("NewRequest failed:", err)
+	}
+	c.RetryPolicy = nil
+	_, err = c.Do(context.TODO(), req, nil)
+	if err == nil || !strings.Contains(err.Error(), "no recorded interaction left") {
+		t.Errorf("Expected replaying a missing interaction to fail, got %v", err)
+	}
+}
+
+func TestSanitizeURL(t *testing.T) {
+	tests := map[string]string{
+		"https://cs.github.com/api/search?q=a":                        "https://cs.github.com/api/search?q=a",
+		"https://api.github.com/x?client_secret=s&q=a":                "https://api.github.com/x?client_secret=REDACTED&q=a",
+		"https://api.github.com/x?access_token=s&token=t":             "https://api.github.com/x?access_token=REDACTED&token=REDACTED",
+		"https://cs.github.com/api/search?q=path%3A%2A%2A.java+class": "https://cs.github.com/api/search?q=path%3A%2A%2A.java+class",
+	}
+	for in, expected := range tests {
+		u, err := url.Parse(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s := sanitizeURL(u).String(); s != expected {
+			t.Errorf("Expected %s to be sanitized to %s, got %s", in, expected, s)
+		}
+	}
+}
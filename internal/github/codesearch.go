@@ -3,8 +3,8 @@ package github
 import (
 	"context"
 	"fmt"
-	"net/http"
 
+	"github.com/abergmeier/knollledge/internal/search"
 	qs "github.com/google/go-querystring/query"
 )
 
@@ -14,17 +14,26 @@ type SearchOptions struct {
 	ListOptions
 }
 
-func (s *CodeSearchService) Search(ctx context.Context, query string, opts *SearchOptions) (*CodeSearchResult, *http.Response, error) {
+func (s *CodeSearchService) Search(ctx context.Context, query string, opts *SearchOptions) (*CodeSearchResult, *Response, error) {
 	result := new(CodeSearchResult)
 	resp, err := s.search(ctx, &searchParameters{Query: query}, opts, result)
 	if err != nil {
 		return nil, resp, err
 	}
 
+	if result.Failed || len(result.QueryErrors) != 0 {
+		return nil, resp, &QueryError{
+			Query:     query,
+			Message:   result.Error,
+			Details:   result.QueryErrors,
+			RequestId: result.RequestId,
+		}
+	}
+
 	return result, resp, nil
 }
 
-func (s *CodeSearchService) search(ctx context.Context, parameters *searchParameters, opts *SearchOptions, result interface{}) (*http.Response, error) {
+func (s *CodeSearchService) search(ctx context.Context, parameters *searchParameters, opts *SearchOptions, result interface{}) (*Response, error) {
 
 	params, err := qs.Values(opts)
 	if err != nil {
@@ -43,32 +52,40 @@ func (s *CodeSearchService) search(ctx context.Context, parameters *searchParame
 	return s.client.Do(ctx, req, result)
 }
 
-type CodeResult struct {
-	Path      string `json:"path,omitempty"`
-	Sha       string `json:"sha,omitempty"`
-	RefName   string `json:"ref_name,omitempty"`
-	Language  string `json:"language,omitempty"`
-	RepoId    uint64 `json:"repo_id,omitempty"`
-	CommitSha string `json:"commit_sha,omitempty"`
-	RepoName  string `json:"repo_name,omitempty"`
-}
+// The results of code search are the backend-neutral results of package
+// search, which mirror the JSON of cs.github.com.
+type (
+	CodeResult  = search.Result
+	Snippet     = search.Snippet
+	Match       = search.Match
+	ScoringInfo = search.ScoringInfo
+	FacetGroup  = search.FacetGroup
+	Facet       = search.Facet
+)
 
 type CodeSearchResult struct {
-	Error                string        `json:"error,omitempty"`
-	Failed               bool          `json:"failed,omitempty"`
-	EpochId              int64         `json:"epoch_id,omitempty"`
-	IndexVersion         int64         `json:"index_version,omitempty"`
-	RequestId            string        `json:"request_id,omitempty"`
-	ResultsCount         uint64        `json:"results_count,omitempty"`
-	IsTreelightsAvail    bool          `json:"is_treelights_avail,omitempty"`
-	SearchElapsedMs      uint64        `json:"search_elapsed_ms,omitempty"`
-	PageToken            string        `json:"page_token,omitempty"`
-	PageNumber           uint          `json:"page_number,omitempty"`
-	TotalPages           uint          `json:"total_pages,omitempty"`
-	ServingOffsetQueried uint64        `json:"serving_offset_queried,omitempty"`
-	Results              []*CodeResult `json:"results,omitempty"`
+	Error                string              `json:"error,omitempty"`
+	Failed               bool                `json:"failed,omitempty"`
+	EpochId              int64               `json:"epoch_id,omitempty"`
+	IndexVersion         int64               `json:"index_version,omitempty"`
+	RequestId            string              `json:"request_id,omitempty"`
+	ResultsCount         uint64              `json:"results_count,omitempty"`
+	IsTreelightsAvail    bool                `json:"is_treelights_avail,omitempty"`
+	SearchElapsedMs      uint64              `json:"search_elapsed_ms,omitempty"`
+	Facets               []*FacetGroup       `json:"facets,omitempty"`
+	QueryErrors          []*QueryErrorDetail `json:"query_errors,omitempty"`
+	PageToken            string              `json:"page_token,omitempty"`
+	PageNumber           uint                `json:"page_number,omitempty"`
+	TotalPages           uint                `json:"total_pages,omitempty"`
+	ServingOffsetQueried uint64              `json:"serving_offset_queried,omitempty"`
+	Results              []*CodeResult       `json:"results,omitempty"`
 }
 
+const (
+	FacetKindLanguages    = search.FacetKindLanguages
+	FacetKindRepositories = search.FacetKindRepositories
+)
+
 type searchParameters struct {
 	Query string
 }
@@ -1,7 +1,6 @@
 package github
 
 import (
-	"context"
 	"encoding/json"
 	"os"
 	"testing"
@@ -20,6 +19,38 @@ var (
 				RepoId:    83222441,
 				CommitSha: "a07e261677c012d37d26255de6e7b128a2643946",
 				RepoName:  "donnemartin/system-design-primer",
+				Snippets: []*Snippet{
+					{
+						Lines: []string{
+							"<span class=\"pl-c1\"><span class=\"pl-c1\">```</span></span>",
+							"<span class=\"pl-c1\">Availability (Total) = Availability (<mark>Foo</mark>) * Availability (Bar)</span>",
+							"<span class=\"pl-c1\"><span class=\"pl-c1\">```</span></span>",
+						},
+						StartLine: 565,
+					},
+					{
+						Lines: []string{
+							"",
+							"If both <span class=\"pl-c1\">`<mark>Foo</mark>`</span> and <span class=\"pl-c1\">`Bar`</span> each had 99.9% availability, their total availability in sequence would be 99.8%.",
+							"",
+						},
+						StartLine: 568,
+					},
+				},
+				MatchCount: 5,
+				Matches: []*Match{
+					{Start: 26471, End: 26474},
+					{Start: 26511, End: 26514},
+					{Start: 26773, End: 26776},
+					{Start: 26820, End: 26823},
+					{Start: 67535, End: 67538},
+				},
+				ScoringInfo: &ScoringInfo{
+					Score:         -0.97829837,
+					Factors:       []int{1, 9, 11, 5, 4, 7, 10},
+					Contributions: []float64{-1.546554, -0.8997302, 0, 1, 0.46798593, 0, 0},
+					SymbolMatches: 0,
+				},
 			},
 			{
 
@@ -30,14 +61,71 @@ var (
 				RepoId:    21737465,
 				CommitSha: "b26d26bd1ad3e80f971edd78640d5a98b2c8e875",
 				RepoName:  "sindresorhus/awesome",
+				Snippets: []*Snippet{
+					{
+						Lines: []string{
+							"<span class=\"pl-v\">-</span> [<span class=\"pl-e\">Board Games</span>](https://github.com/edm00se/awesome-board-games#readme) - Table-top gaming fun for all.",
+							"<span class=\"pl-v\">-</span> [<span class=\"pl-e\">Software Patreons</span>](https://github.com/uraimo/awesome-software-patreons#readme) - Fund individual programmers or the development of open source projects.",
+							"<span class=\"pl-v\">-</span> [<span class=\"pl-e\">Parasite</span>](https://github.com/ecohealthalliance/awesome-parasite#readme) - Parasites and host-pathogen interactions.",
+							"<span class=\"pl-v\">-</span> [<span class=\"pl-e\"><mark>Foo</mark>d</span>](https://github.com/jzarca01/awesome-<mark>foo</mark>d#readme) - <mark>Foo</mark>d-related projects on GitHub.",
+							"<span class=\"pl-v\">-</span> [<span class=\"pl-e\">Bitcoin Payment Processors</span>](https://github.com/alexk111/awesome-bitcoin-payment-processors#readme) - Start accepting Bitcoin.",
+							"<span class=\"pl-v\">-</span> [<span class=\"pl-e\">Scientific Computing</span>](https://github.com/nschloe/awesome-scientific-computing#readme) - Solving complex scientific problems using computers.",
+							"<span class=\"pl-v\">-</span> [<span class=\"pl-e\">Amazon Sellers</span>](https://github.com/ScaleLeap/awesome-amazon-seller#readme)",
+						},
+						StartLine: 888,
+					},
+				},
+				MatchCount: 3,
+				Matches: []*Match{
+					{Start: 70524, End: 70527},
+					{Start: 70566, End: 70569},
+					{Start: 70581, End: 70584},
+				},
+				ScoringInfo: &ScoringInfo{
+					Score:         -11.097456,
+					Factors:       []int{1, 9, 11, 5, 4, 7, 10, 13},
+					Contributions: []float64{-1.1081359, -0.8997302, 0, 1, 0.41040987, -10, 0, -0.5},
+					SymbolMatches: 0,
+				},
+			},
+		},
+		EpochId:           298,
+		IndexVersion:      49,
+		RequestId:         "A186:5796:68DC:3C8A2:64419E90",
+		ResultsCount:      100,
+		IsTreelightsAvail: true,
+		SearchElapsedMs:   228,
+		Facets: []*FacetGroup{
+			{
+				Kind: FacetKindLanguages,
+				Facets: []*Facet{
+					{Name: "Markdown", Query: "language:Markdown", Occurrences: 71, Score: 0.3759674267529132},
+					{Name: "Makefile", Query: "language:Makefile", Occurrences: 16, Score: 1.0768548826516802e-07},
+					{Name: "C", Query: "language:C", Occurrences: 12, Score: 3.946909264709586e-14},
+					{Name: "TypeScript", Query: "language:TypeScript", Occurrences: 1, Score: 1.7758695829117675e-17},
+				},
+			},
+			{
+				Kind: FacetKindRepositories,
+				Facets: []*Facet{
+					{Name: "donnemartin/system-design-primer", Owner: "donnemartin", Query: "repo:donnemartin/system-design-primer", Occurrences: 1, Score: 0.37595028462745694},
+					{Name: "sindresorhus/awesome", Owner: "sindresorhus", Query: "repo:sindresorhus/awesome", Occurrences: 1, Score: 1.515081884350049e-05},
+					{Name: "freeCodeCamp/freeCodeCamp", Owner: "freeCodeCamp", Query: "repo:freeCodeCamp/freeCodeCamp", Occurrences: 1, Score: 1.984487544155557e-06},
+					{Name: "beagleboard/linux", Owner: "beagleboard", Query: "repo:beagleboard/linux", Occurrences: 1, Score: 1.0768331374579932e-07},
+					{Name: "baidu-research/tensorflow-allreduce", Owner: "baidu-research", Query: "repo:baidu-research/tensorflow-allreduce", Occurrences: 1, Score: 6.816843872917911e-09},
+					{Name: "anthraxx/linux-hardened", Owner: "anthraxx", Query: "repo:anthraxx/linux-hardened", Occurrences: 1, Score: 1.971311603329036e-12},
+					{Name: "probonopd/awesome", Owner: "probonopd", Query: "repo:probonopd/awesome", Occurrences: 1, Score: 1.8541625975988136e-12},
+					{Name: "andr2000/linux", Owner: "andr2000", Query: "repo:andr2000/linux", Occurrences: 1, Score: 6.180565248725497e-14},
+					{Name: "labuladong/system-design-primer", Owner: "labuladong", Query: "repo:labuladong/system-design-primer", Occurrences: 1, Score: 5.009978520911522e-14},
+					{Name: "jfinal/tensorflow", Owner: "jfinal", Query: "repo:jfinal/tensorflow", Occurrences: 1, Score: 4.1546461693459005e-14},
+					{Name: "rookiejava/tensorflow", Owner: "rookiejava", Query: "repo:rookiejava/tensorflow", Occurrences: 1, Score: 4.094902311090316e-14},
+					{Name: "Lyude/linux", Owner: "Lyude", Query: "repo:Lyude/linux", Occurrences: 1, Score: 4.0235812560280876e-14},
+					{Name: "xiamaz/tensorflow", Owner: "xiamaz", Query: "repo:xiamaz/tensorflow", Occurrences: 1, Score: 3.973172092092724e-14},
+					{Name: "brauner/linux", Owner: "brauner", Query: "repo:brauner/linux", Occurrences: 1, Score: 3.96095209046877e-14},
+					{Name: "6by9/upstream-linux", Owner: "6by9", Query: "repo:6by9/upstream-linux", Occurrences: 1, Score: 3.902102659003251e-14},
+				},
 			},
 		},
-		EpochId:              298,
-		IndexVersion:         49,
-		RequestId:            "A186:5796:68DC:3C8A2:64419E90",
-		ResultsCount:         100,
-		IsTreelightsAvail:    true,
-		SearchElapsedMs:      228,
 		PageToken:            "7f41e7bd52ed2fb676a8801ec3ec1ea4da297b2ea28bb37410b7da990fcee96c88d7927f3ccae2544a1deb36c08362d39ca3b87e1d529835fe6d0526238a7ae354edea4b1a2628ee11010c1bb5d384725f008f126b101fbc3db4cb06220be36411339110bbe95077fc6b61288429ee59b32511499d4903de086c73476fb794457f58d0382d4057337bb1e3e10b9d5afd763ef3b2a897c50d8b7bf729e63971ef1f6b6d7e353bbf8142cb8cffbb19f047fd894e4eaac2405fdc6295f933fce86ba5e9d938eb1f59738db6be7d329a88572aeafaeef3b843f93160dff6db70e6d6206f8ae09fce8b915fc04c87bf3786e47214f075ca2af1abab067d515cd28abdad852e4739de1d6a6f3aa94ceed0f3cb39b2a0b8eae539d6d33ab74baa59836b786c9a5471205e3d8327db0cfe26380442154e7c4ceba13ae9e3ddaa931e3c38b361d2f2cb41a2cc7dedf1109aa683e271c533596ee9884cf97f6655aa471f89f2d0b08187e911eaa3d2a37079933efbd8db1f72b906ff70255cec57fd43e0e3498a317815de279cb608ec326155a9bb",
 		PageNumber:           1,
 		TotalPages:           5,
@@ -45,27 +133,6 @@ var (
 	}
 )
 
-func TestCodeSearch(t *testing.T) {
-	client := NewTestClient()
-	result, _, err := client.CodeSearch().Search(context.TODO(), "path:**.java class", &SearchOptions{})
-	if err != nil {
-		t.Fatal("Initial search failed:", err)
-	}
-
-	result, _, err = client.CodeSearch().Search(context.TODO(), "path:**.java class", &SearchOptions{
-		ListOptions: ListOptions{
-			Page:      2,
-			PageToken: result.PageToken,
-		},
-	})
-	if err != nil {
-		t.Fatal("Secondary search failed:", err)
-	}
-	if result.PageNumber != 2 {
-		t.Fatal("Invalid PageNumber returned (2 expected):", result.PageNumber)
-	}
-}
-
 func TestCodeSearch2(t *testing.T) {
 
 }
@@ -15,7 +15,6 @@ import (
 	"time"
 
 	"github.com/google/go-github/v52/github"
-	"golang.org/x/oauth2"
 )
 
 const (
@@ -40,14 +39,31 @@ type client struct {
 	CodeSearchURL *url.URL
 
 	rateMu                  sync.Mutex
+	rate                    Rate
 	secondaryRateLimitReset time.Time
 
 	UserAgent string
+
+	// Authenticator adds credentials to every request created by
+	// NewRequest. Nil sends requests anonymously.
+	Authenticator Authenticator
+
+	// RetryPolicy decides which failed requests Do repeats. Nil disables
+	// retries.
+	RetryPolicy *RetryPolicy
+
+	// Limiter paces requests and enforces a request budget. It may be
+	// shared by several clients. Nil sends requests without limits.
+	Limiter *Limiter
+
+	// Cache stores responses on disk. Responses served from it do not
+	// count against the Limiter. Nil disables caching.
+	Cache *Cache
 }
 
 type Client interface {
 	CodeSearch() *CodeSearchService
-	Do(ctx context.Context, req *http.Request, v interface{}) (*http.Response, error)
+	Do(ctx context.Context, req *http.Request, v interface{}) (*Response, error)
 	NewRequest(method, urlStr string, body interface{}, opts ...RequestOption) (*http.Request, error)
 }
 
@@ -57,18 +73,29 @@ func NewClient(httpClient *http.Client) *client {
 	}
 	codeSearchURL, _ := url.Parse(defaultCodeSearchURL)
 
-	c := &client{client: httpClient, CodeSearchURL: codeSearchURL}
+	retryPolicy := DefaultRetryPolicy
+	c := &client{client: httpClient, CodeSearchURL: codeSearchURL, RetryPolicy: &retryPolicy}
 	c.common.client = c
 	return c
 }
 
 // NewTokenClient returns a new GitHub API client authenticated with the provided token.
 func NewTokenClient(ctx context.Context, token string) *client {
-	return NewClient(oauth2.NewClient(ctx, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})))
+	c := NewClient(nil)
+	c.Authenticator = &TokenAuthenticator{Token: token}
+	return c
 }
 
 type RequestOption func(req *http.Request)
 
+// Response wraps the standard http.Response and exposes the rate limit
+// state reported with it.
+type Response struct {
+	*http.Response
+
+	Rate Rate
+}
+
 // ListOptions specifies the optional parameters to various List methods that
 // support offset pagination.
 type ListOptions struct {
@@ -123,42 +150,46 @@ func (c *client) NewRequest(method, urlStr string, body interface{}, opts ...Req
 		opt(req)
 	}
 
+	if c.Authenticator != nil {
+		err := c.Authenticator.Authenticate(req)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return req, nil
 }
 
-func (c *client) BareDo(ctx context.Context, req *http.Request) (*http.Response, error) {
+func (c *client) BareDo(ctx context.Context, req *http.Request) (*Response, error) {
 	if ctx == nil {
 		return nil, errNonNilContext
 	}
 
 	req = withContext(ctx, req)
 
-	resp, err := c.client.Do(req)
+	var resp *http.Response
+	var err error
+	if c.Cache != nil {
+		resp, err = c.Cache.do(req, c.send)
+	} else {
+		resp, err = c.send(req)
+	}
 	if err != nil {
-		// If we got an error, and the context has been canceled,
-		// the context's error is probably more useful.
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		default:
-		}
-
-		// If the error type is *url.Error, sanitize its URL before returning.
-		if e, ok := err.(*url.Error); ok {
-			if url, err := url.Parse(e.URL); err == nil {
-				e.URL = sanitizeURL(url).String()
-				return nil, e
-			}
-		}
-
 		return nil, err
 	}
+	body := resp.Body
 
-	response := resp
+	rate, ok := parseRate(resp)
+	if ok {
+		c.updateRate(rate)
+	}
+	response := &Response{Response: resp, Rate: rate}
 
 	err = CheckResponse(resp)
 	if err != nil {
-		defer resp.Body.Close()
+		// CheckResponse may replace the body. Closing the original one
+		// frees its slot in the Limiter.
+		defer body.Close()
 		// Special case for AcceptedErrors. If an AcceptedError
 		// has been encountered, the response's payload will be
 		// added to the AcceptedError and returned.
@@ -186,7 +217,53 @@ func (c *client) BareDo(ctx context.Context, req *http.Request) (*http.Response,
 	return response, err
 }
 
+// send sends req once the rate limits and the Limiter allow it.
+func (c *client) send(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if err := c.waitRateLimit(ctx); err != nil {
+		return nil, err
+	}
+
+	release := func() {}
+	if c.Limiter != nil {
+		var err error
+		release, err = c.Limiter.Wait(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		release()
+
+		// If we got an error, and the context has been canceled,
+		// the context's error is probably more useful.
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		// If the error type is *url.Error, sanitize its URL before returning.
+		if e, ok := err.(*url.Error); ok {
+			if url, err := url.Parse(e.URL); err == nil {
+				e.URL = sanitizeURL(url).String()
+				return nil, e
+			}
+		}
+
+		return nil, err
+	}
+
+	resp.Body = &limitedBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
 func CheckResponse(r *http.Response) error {
+	if err := checkSession(r); err != nil {
+		return err
+	}
 	if r.StatusCode == http.StatusAccepted {
 		return &AcceptedError{}
 	}
@@ -207,7 +284,9 @@ func CheckResponse(r *http.Response) error {
 	case r.StatusCode == http.StatusUnauthorized && strings.HasPrefix(r.Header.Get(headerOTP), "required"):
 		return (*github.TwoFactorAuthError)(errorResponse)
 	case r.StatusCode == http.StatusForbidden && r.Header.Get(headerRateRemaining) == "0":
+		rate, _ := parseRate(r)
 		return &github.RateLimitError{
+			Rate:     rate.toGitHub(),
 			Response: errorResponse.Response,
 			Message:  errorResponse.Message,
 		}
@@ -232,8 +311,8 @@ func CheckResponse(r *http.Response) error {
 	}
 }
 
-func (c *client) Do(ctx context.Context, req *http.Request, v interface{}) (*http.Response, error) {
-	resp, err := c.BareDo(ctx, req)
+func (c *client) Do(ctx context.Context, req *http.Request, v interface{}) (*Response, error) {
+	resp, err := c.doWithRetry(ctx, req)
 	if err != nil {
 		return resp, err
 	}
@@ -255,13 +334,22 @@ func (c *client) Do(ctx context.Context, req *http.Request, v interface{}) (*htt
 	return resp, err
 }
 
+// sensitiveParams are query parameters carrying credentials.
+var sensitiveParams = []string{"access_token", "client_secret", "token"}
+
 func sanitizeURL(uri *url.URL) *url.URL {
 	if uri == nil {
 		return nil
 	}
 	params := uri.Query()
-	if len(params.Get("client_secret")) > 0 {
-		params.Set("client_secret", "REDACTED")
+	redacted := false
+	for _, p := range sensitiveParams {
+		if len(params.Get(p)) > 0 {
+			params.Set(p, "REDACTED")
+			redacted = true
+		}
+	}
+	if redacted {
 		uri.RawQuery = params.Encode()
 	}
 	return uri
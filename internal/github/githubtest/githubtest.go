@@ -0,0 +1,78 @@
+// Package githubtest provides clients for tests searching a fake server
+// or replaying recorded exchanges.
+package githubtest
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/abergmeier/knollledge/internal/config"
+	"github.com/abergmeier/knollledge/internal/fake"
+	"github.com/abergmeier/knollledge/internal/github"
+)
+
+// NewTestClient returns a client searching a fake code search server over
+// fake.DefaultCorpus. The server is shut down when tb finishes.
+func NewTestClient(tb testing.TB) github.Client {
+	return NewFakeClient(tb, fake.NewServer(fake.DefaultCorpus()))
+}
+
+// NewFakeClient returns a client searching srv, which is shut down when tb
+// finishes. Retries happen without delay.
+func NewFakeClient(tb testing.TB, srv *fake.Server) github.Client {
+	tb.Cleanup(srv.Close)
+
+	c := github.NewClient(nil)
+	c.CodeSearchURL, _ = url.Parse(srv.CodeSearchURL())
+	c.RetryPolicy = &github.RetryPolicy{
+		MaxAttempts: github.DefaultRetryPolicy.MaxAttempts,
+		Retry:       github.RetryAll,
+	}
+	return c
+}
+
+// NewCassetteClient returns a client replaying the cassette at path. If
+// RecordEnv is set, the client searches cs.github.com with the session
+// cookies the command line tool uses by default instead and records the
+// exchanges to path when tb finishes. Tests fail if their cassette has
+// not been recorded.
+func NewCassetteClient(tb testing.TB, path string) github.Client {
+	t := &github.CassetteTransport{
+		Cassette: &github.Cassette{},
+		Record:   os.Getenv(github.RecordEnv) != "",
+	}
+	c := github.NewClient(&http.Client{Transport: t})
+	c.RetryPolicy = &github.RetryPolicy{
+		MaxAttempts: github.DefaultRetryPolicy.MaxAttempts,
+		Retry:       github.RetryAll,
+	}
+
+	if !t.Record {
+		cassette, err := github.LoadCassette(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			tb.Fatalf("Cassette %s not recorded, run with %s=1", path, github.RecordEnv)
+		}
+		if err != nil {
+			tb.Fatal("Loading cassette failed:", err)
+		}
+		t.Cassette = cassette
+		return c
+	}
+
+	creds, err := (&config.Auth{Method: config.AuthCookie}).Credentials()
+	if err != nil {
+		tb.Fatal("Loading credentials failed:", err)
+	}
+	t.Transport = github.NewPool(creds...)
+	tb.Cleanup(func() {
+		err := t.Cassette.Save(path)
+		if err != nil {
+			tb.Error("Saving cassette failed:", err)
+		}
+	})
+	return c
+}
@@ -0,0 +1,138 @@
+package github
+
+import "context"
+
+// PageIterator walks the pages of a query, carrying the page token of each
+// page forward to the next request. It stops after the last page, on the
+// first error or when its context is done.
+//
+//	it := client.CodeSearch().Pages(ctx, query)
+//	for it.Next() {
+//		page := it.Page()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type PageIterator struct {
+	ctx   context.Context
+	s     *CodeSearchService
+	query string
+
+	next ListOptions
+	page *CodeSearchResult
+	resp *Response
+	err  error
+	done bool
+}
+
+// Pages returns an iterator over all pages of query.
+func (s *CodeSearchService) Pages(ctx context.Context, query string) *PageIterator {
+	return &PageIterator{
+		ctx:   ctx,
+		s:     s,
+		query: query,
+	}
+}
+
+// Next fetches the next page. It returns false when there are no more
+// pages or fetching failed, Err tells the two apart.
+func (it *PageIterator) Next() bool {
+	if it.done || it.err != nil {
+		return false
+	}
+	if err := it.ctx.Err(); err != nil {
+		it.err = err
+		return false
+	}
+
+	page, resp, err := it.s.Search(it.ctx, it.query, &SearchOptions{ListOptions: it.next})
+	it.resp = resp
+	if err != nil {
+		it.err = err
+		return false
+	}
+
+	it.page = page
+	if next := page.NextPage(); next != nil {
+		it.next = *next
+	} else {
+		it.done = true
+	}
+	return true
+}
+
+// NextPage returns the options requesting the page following r, or nil if
+// r is the last page. Pages without a number count as the last, so
+// callers do not request the same page over and over.
+func (r *CodeSearchResult) NextPage() *ListOptions {
+	if r.PageNumber == 0 || r.PageNumber >= r.TotalPages {
+		return nil
+	}
+	return &ListOptions{
+		Page:      int(r.PageNumber + 1),
+		PageToken: r.PageToken,
+	}
+}
+
+// Page returns the page fetched by the last call to Next.
+func (it *PageIterator) Page() *CodeSearchResult {
+	return it.page
+}
+
+// Response returns the response of the last request, which may be a
+// failed one.
+func (it *PageIterator) Response() *Response {
+	return it.resp
+}
+
+// Err returns the error which stopped the iteration, if any.
+func (it *PageIterator) Err() error {
+	return it.err
+}
+
+// ResultIterator walks the results of all pages of a query, fetching
+// pages as needed.
+type ResultIterator struct {
+	pages   *PageIterator
+	results []*CodeResult
+	result  *CodeResult
+}
+
+// Results returns an iterator over all results of query.
+func (s *CodeSearchService) Results(ctx context.Context, query string) *ResultIterator {
+	return &ResultIterator{
+		pages: s.Pages(ctx, query),
+	}
+}
+
+// Next advances to the next result. It returns false when there are no
+// more results or fetching a page failed, Err tells the two apart.
+func (it *ResultIterator) Next() bool {
+	for len(it.results) == 0 {
+		if !it.pages.Next() {
+			it.result = nil
+			return false
+		}
+		it.results = it.pages.Page().Results
+	}
+
+	it.result = it.results[0]
+	it.results = it.results[1:]
+	return true
+}
+
+// Result returns the result the last call to Next advanced to.
+func (it *ResultIterator) Result() *CodeResult {
+	return it.result
+}
+
+// Page returns the page the current result belongs to.
+func (it *ResultIterator) Page() *CodeSearchResult {
+	return it.pages.Page()
+}
+
+// Err returns the error which stopped the iteration, if any.
+func (it *ResultIterator) Err() error {
+	return it.pages.Err()
+}
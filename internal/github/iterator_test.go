@@ -0,0 +1,99 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// pagesHandler serves 3 pages with two results each. Every page but the
+// first requires the token handed out with the previous page.
+func pagesHandler(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		pn := 1
+		if p := r.URL.Query().Get("p"); p != "" {
+			pn, _ = strconv.Atoi(p)
+		}
+		if token := r.URL.Query().Get("pageToken"); pn > 1 && token != fmt.Sprint("token", pn-1) {
+			t.Errorf("Page %d requested with token %q", pn, token)
+		}
+		fmt.Fprintf(w, `{"page_number": %d, "total_pages": 3, "page_token": "token%d", "results": [{"path": "%d-a"}, {"path": "%d-b"}]}`, pn, pn, pn, pn)
+	}
+}
+
+func TestPageIterator(t *testing.T) {
+	c := newRateLimitTestClient(t, pagesHandler(t))
+
+	pages := []uint{}
+	it := c.CodeSearch().Pages(context.TODO(), "foo")
+	for it.Next() {
+		pages = append(pages, it.Page().PageNumber)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal("Iteration failed:", err)
+	}
+	if diff := cmp.Diff(pages, []uint{1, 2, 3}); diff != "" {
+		t.Errorf("Unexpected pages:\n%s\n", diff)
+	}
+	if it.Next() {
+		t.Error("Next returned true after last page")
+	}
+}
+
+func TestResultIterator(t *testing.T) {
+	c := newRateLimitTestClient(t, pagesHandler(t))
+
+	paths := []string{}
+	it := c.CodeSearch().Results(context.TODO(), "foo")
+	for it.Next() {
+		paths = append(paths, it.Result().Path)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal("Iteration failed:", err)
+	}
+	if diff := cmp.Diff(paths, []string{"1-a", "1-b", "2-a", "2-b", "3-a", "3-b"}); diff != "" {
+		t.Errorf("Unexpected results:\n%s\n", diff)
+	}
+}
+
+func TestIteratorCanceled(t *testing.T) {
+	c := newRateLimitTestClient(t, pagesHandler(t))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	it := c.CodeSearch().Results(ctx, "foo")
+	n := 0
+	for it.Next() {
+		n++
+		if n == 3 {
+			cancel()
+		}
+	}
+	if !errors.Is(it.Err(), context.Canceled) {
+		t.Fatal("Expected context.Canceled, got:", it.Err())
+	}
+	if n != 4 {
+		t.Error("Iteration went on after cancelation, results:", n)
+	}
+}
+
+func TestNextPage(t *testing.T) {
+	tests := []struct {
+		result   CodeSearchResult
+		expected *ListOptions
+	}{
+		{CodeSearchResult{PageNumber: 1, TotalPages: 3, PageToken: "t"}, &ListOptions{Page: 2, PageToken: "t"}},
+		{CodeSearchResult{PageNumber: 3, TotalPages: 3}, nil},
+		{CodeSearchResult{PageNumber: 0, TotalPages: 3}, nil},
+	}
+	for _, test := range tests {
+		if diff := cmp.Diff(test.result.NextPage(), test.expected); diff != "" {
+			t.Errorf("Unexpected next page of %+v:\n%s", test.result, diff)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrBudgetExhausted is returned instead of sending a request once the
+// request budget of a Limiter is used up.
+var ErrBudgetExhausted = errors.New("request budget exhausted")
+
+// Limiter paces the requests of all clients and jobs sharing it, so
+// running jobs concurrently does not just trip the secondary rate limit
+// faster. Requests are paced by a token bucket refilled with Rate tokens
+// per second and holding up to Burst tokens.
+//
+// The zero Limiter does not limit anything.
+type Limiter struct {
+	// Rate is the number of requests per second. Zero disables pacing.
+	Rate float64
+	// Burst is the number of requests which may be sent at once. Values
+	// below 1 mean 1.
+	Burst int
+	// MaxInFlight is the number of requests which may be outstanding at
+	// the same time. Zero means no limit.
+	MaxInFlight int
+	// Budget is the number of requests which may be sent over the
+	// lifetime of the Limiter, retries included. Zero means no limit.
+	Budget int
+
+	mu       sync.Mutex
+	tokens   float64
+	last     time.Time
+	used     int
+	inFlight chan struct{}
+}
+
+// Wait blocks until a request may be sent. The returned function must be
+// called once the request finished. Wait fails with ErrBudgetExhausted
+// if the budget is used up.
+func (l *Limiter) Wait(ctx context.Context) (func(), error) {
+	l.mu.Lock()
+	if l.Budget > 0 && l.used >= l.Budget {
+		l.mu.Unlock()
+		return nil, ErrBudgetExhausted
+	}
+	l.used++
+	if l.MaxInFlight > 0 && l.inFlight == nil {
+		l.inFlight = make(chan struct{}, l.MaxInFlight)
+	}
+	inFlight := l.inFlight
+	l.mu.Unlock()
+
+	if inFlight != nil {
+		select {
+		case inFlight <- struct{}{}:
+		case <-ctx.Done():
+			l.refund(false)
+			return nil, ctx.Err()
+		}
+	}
+	release := func() {
+		if inFlight != nil {
+			<-inFlight
+		}
+	}
+
+	if wait := l.reserve(); wait > 0 {
+		t := time.NewTimer(wait)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			release()
+			l.refund(true)
+			return nil, ctx.Err()
+		}
+	}
+
+	once := sync.Once{}
+	return func() { once.Do(release) }, nil
+}
+
+// reserve takes a token from the bucket and returns how long to wait for
+// it. The bucket goes negative while requests wait, which queues them.
+func (l *Limiter) reserve() time.Duration {
+	if l.Rate <= 0 {
+		return 0
+	}
+	burst := float64(l.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if l.last.IsZero() {
+		l.tokens = burst
+	} else {
+		l.tokens += now.Sub(l.last).Seconds() * l.Rate
+		if l.tokens > burst {
+			l.tokens = burst
+		}
+	}
+	l.last = now
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.Rate * float64(time.Second))
+}
+
+// refund returns the budget, and if token is set the token, of a request
+// which was not sent.
+func (l *Limiter) refund(token bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.used--
+	if token && l.Rate > 0 {
+		l.tokens++
+	}
+}
+
+// Used returns the number of requests sent or waiting to be sent.
+func (l *Limiter) Used() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.used
+}
+
+// Exhausted tells whether the budget is used up.
+func (l *Limiter) Exhausted() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.Budget > 0 && l.used >= l.Budget
+}
+
+// limitedBody releases the in-flight slot of a request once its response
+// has been read.
+type limitedBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *limitedBody) Close() error {
+	defer b.release()
+	return b.ReadCloser.Close()
+}
+
+// LimitTransport is an http.RoundTripper sending requests as allowed by
+// Limiter. It makes a Limiter usable with HTTP clients not created by
+// NewClient, e.g. the one of go-github. Requests a Pool in Base asks to
+// resend with another credential are resent as allowed by Limiter too.
+type LimitTransport struct {
+	Limiter *Limiter
+	// Base sends the requests. Defaults to http.DefaultTransport.
+	Base http.RoundTripper
+}
+
+func (t *LimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	for {
+		release, err := t.Limiter.Wait(req.Context())
+		if err != nil {
+			if req.Body != nil {
+				req.Body.Close()
+			}
+			return nil, err
+		}
+
+		resp, err := base.RoundTrip(req)
+		if errors.Is(err, errResend) {
+			release()
+			continue
+		}
+		if err != nil {
+			release()
+			return nil, err
+		}
+		resp.Body = &limitedBody{ReadCloser: resp.Body, release: release}
+		return resp, nil
+	}
+}
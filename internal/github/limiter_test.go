@@ -0,0 +1,110 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/abergmeier/knollledge/internal/fake"
+)
+
+func TestLimiterBudget(t *testing.T) {
+	l := &Limiter{Budget: 2}
+	for i := 0; i < 2; i++ {
+		release, err := l.Wait(context.TODO())
+		if err != nil {
+			t.Fatal("Wait failed:", err)
+		}
+		release()
+	}
+	if !l.Exhausted() {
+		t.Error("Expected budget to be exhausted")
+	}
+	_, err := l.Wait(context.TODO())
+	if !errors.Is(err, ErrBudgetExhausted) {
+		t.Errorf("Expected ErrBudgetExhausted, got %v", err)
+	}
+	if l.Used() != 2 {
+		t.Errorf("Expected 2 requests used, got %d", l.Used())
+	}
+}
+
+func TestLimiterInFlight(t *testing.T) {
+	l := &Limiter{MaxInFlight: 2}
+	release, err := l.Wait(context.TODO())
+	if err != nil {
+		t.Fatal("Wait failed:", err)
+	}
+	_, err = l.Wait(context.TODO())
+	if err != nil {
+		t.Fatal("Wait failed:", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = l.Wait(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected third request to wait for a slot, got %v", err)
+	}
+	if l.Used() != 2 {
+		t.Errorf("Expected request which was not sent to be refunded, got %d used", l.Used())
+	}
+
+	release()
+	release()
+	_, err = l.Wait(context.TODO())
+	if err != nil {
+		t.Fatal("Wait after release failed:", err)
+	}
+}
+
+func TestLimiterRate(t *testing.T) {
+	l := &Limiter{Rate: 100, Burst: 2}
+	start := time.Now()
+	wg := sync.WaitGroup{}
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := l.Wait(context.TODO())
+			if err != nil {
+				t.Error("Wait failed:", err)
+				return
+			}
+			release()
+		}()
+	}
+	wg.Wait()
+
+	// 2 requests go out at once, the other 4 are paced 10ms apart.
+	if d := time.Since(start); d < 35*time.Millisecond {
+		t.Errorf("Expected requests to be paced, took %s", d)
+	}
+}
+
+func TestClientBudget(t *testing.T) {
+	srv := fake.NewServer(fake.DefaultCorpus())
+	srv.Fail(fake.FaultServerError)
+	defer srv.Close()
+	c := NewClient(nil)
+	c.CodeSearchURL, _ = url.Parse(srv.CodeSearchURL())
+	policy := testRetryPolicy
+	c.RetryPolicy = &policy
+	c.Limiter = &Limiter{Budget: 2, MaxInFlight: 1}
+
+	// The retry of the failed first attempt uses up the budget.
+	_, _, err := c.CodeSearch().Search(context.TODO(), "class", &SearchOptions{})
+	if err != nil {
+		t.Fatal("Search failed:", err)
+	}
+	_, _, err = c.CodeSearch().Search(context.TODO(), "class", &SearchOptions{})
+	if !errors.Is(err, ErrBudgetExhausted) {
+		t.Fatalf("Expected ErrBudgetExhausted, got %v", err)
+	}
+	if n := len(srv.Queries()); n != 2 {
+		t.Errorf("Expected 2 requests to reach the server, got %d", n)
+	}
+}
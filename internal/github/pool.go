@@ -0,0 +1,282 @@
+package github
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrNoCredentials is returned instead of sending a request once every
+// credential of a Pool turned out to be invalid.
+var ErrNoCredentials = errors.New("no valid credentials left")
+
+// errResend is returned by a Pool for a request which was rejected or
+// rate limited with one credential while another credential may still
+// send it. Clients resend it, so every attempt passes their Limiter.
+var errResend = errors.New("resend with another credential")
+
+// Credential is a member of a Pool.
+type Credential struct {
+	// Name identifies the credential in logs and reports. It must not
+	// reveal the credential.
+	Name          string
+	Authenticator Authenticator
+}
+
+// Pool is an http.RoundTripper spreading requests over several
+// credentials, so one session's quota does not limit a whole run.
+//
+// Every request is sent with the credential with the most headroom, as
+// reported by the rate limit headers of its last response. Credentials
+// not used yet are tried first. A credential which is rejected, by a 401
+// or a redirect to the login page, is taken out of rotation. Requests
+// rejected or rate limited fail with an error the client retries if
+// another credential has headroom left. The Pool does not resend them
+// itself, that would bypass the Limiter of the client.
+//
+// The rate limit headers of responses are rewritten to describe the
+// credential with the most headroom, so clients only wait for a reset
+// once all credentials are used up.
+type Pool struct {
+	// Base sends the authenticated requests. Defaults to
+	// http.DefaultTransport.
+	Base http.RoundTripper
+
+	mu      sync.Mutex
+	members []*poolMember
+}
+
+type poolMember struct {
+	Credential
+
+	rate         Rate
+	known        bool
+	blockedUntil time.Time
+	invalid      bool
+	requests     int
+	rateLimited  int
+}
+
+// CredentialUsage reports how a credential of a Pool was used.
+type CredentialUsage struct {
+	Name        string
+	Requests    int
+	RateLimited int
+	Invalid     bool
+	// Rate is the rate limit state of the last response, if Known.
+	Rate  Rate
+	Known bool
+}
+
+func (u CredentialUsage) String() string {
+	s := fmt.Sprintf("%s: %d requests, %d rate limited", u.Name, u.Requests, u.RateLimited)
+	if u.Known {
+		s += ", " + u.Rate.String()
+	}
+	if u.Invalid {
+		s += ", invalid"
+	}
+	return s
+}
+
+// NewPool returns a Pool of creds.
+func NewPool(creds ...Credential) *Pool {
+	p := &Pool{}
+	for _, c := range creds {
+		p.members = append(p.members, &poolMember{Credential: c})
+	}
+	return p
+}
+
+// Usage returns the usage of every credential, in the order passed to
+// NewPool.
+func (p *Pool) Usage() []CredentialUsage {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	usage := make([]CredentialUsage, len(p.members))
+	for i, m := range p.members {
+		usage[i] = CredentialUsage{
+			Name:        m.Name,
+			Requests:    m.requests,
+			RateLimited: m.rateLimited,
+			Invalid:     m.invalid,
+			Rate:        m.rate,
+			Known:       m.known,
+		}
+	}
+	return usage
+}
+
+func (p *Pool) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := p.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	// Redirects to the login page are followed without credentials, so
+	// the client can tell the session expired.
+	if isLoginURL(req.URL) {
+		return base.RoundTrip(req)
+	}
+	replayable := req.Body == nil || req.GetBody != nil
+
+	m, err := p.pick()
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+
+	// RoundTrippers must not modify the request they are given.
+	attempt := req.Clone(req.Context())
+	if req.Body != nil && req.GetBody != nil {
+		attempt.Body, err = req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := m.Authenticator.Authenticate(attempt); err != nil {
+		if attempt.Body != nil {
+			attempt.Body.Close()
+		}
+		return nil, fmt.Errorf("credential %s: %w", m.Name, err)
+	}
+
+	resp, err := base.RoundTrip(attempt)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.observe(m, resp) && replayable {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, fmt.Errorf("credential %s: %s: %w", m.Name, resp.Status, errResend)
+	}
+	p.rewriteRate(resp)
+	return resp, nil
+}
+
+// pick returns the credential with the most headroom. Ties go to the one
+// which sent fewer requests.
+func (p *Pool) pick() (*poolMember, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	var best *poolMember
+	bestHeadroom := 0
+	for _, m := range p.members {
+		if m.invalid {
+			continue
+		}
+		h := m.headroom(now)
+		if best == nil || h > bestHeadroom || (h == bestHeadroom && m.requests < best.requests) {
+			best, bestHeadroom = m, h
+		}
+	}
+	if best == nil {
+		return nil, ErrNoCredentials
+	}
+	best.requests++
+	return best, nil
+}
+
+// headroom returns the number of requests m may still send.
+func (m *poolMember) headroom(now time.Time) int {
+	switch {
+	case m.blockedUntil.After(now):
+		return 0
+	case !m.known:
+		return math.MaxInt32
+	case m.rate.Limit > 0 && !m.rate.Reset.IsZero() && !m.rate.Reset.After(now):
+		return m.rate.Limit
+	}
+	return m.rate.Remaining
+}
+
+// observe records the rate state of resp, which was sent with m. It
+// tells whether the request should be resent with another credential.
+func (p *Pool) observe(m *poolMember, resp *http.Response) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if rate, ok := parseRate(resp); ok {
+		m.rate = rate
+		m.known = true
+	}
+
+	var serr *SessionExpiredError
+	rejected := resp.StatusCode == http.StatusUnauthorized || errors.As(checkSession(resp), &serr)
+	limited := (resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests) &&
+		(resp.Header.Get(headerRateRemaining) == "0" || resp.Header.Get("Retry-After") != "")
+	switch {
+	case rejected:
+		m.invalid = true
+		log.Printf("Credential %s was rejected, taking it out of rotation\n", m.Name)
+	case limited:
+		m.rateLimited++
+		if v, err := strconv.ParseInt(resp.Header.Get("Retry-After"), 10, 64); err == nil {
+			m.blockedUntil = time.Now().Add(time.Duration(v) * time.Second)
+		}
+	default:
+		return false
+	}
+
+	// Resend only if another credential can do better.
+	now := time.Now()
+	for _, o := range p.members {
+		if o != m && !o.invalid && o.headroom(now) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// rewriteRate replaces the rate limit headers of resp by the state of the
+// credential with the most headroom.
+func (p *Pool) rewriteRate(resp *http.Response) {
+	rate, ok := parseRate(resp)
+	if !ok {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	var best *poolMember
+	bestHeadroom := -1
+	for _, m := range p.members {
+		if m.invalid {
+			continue
+		}
+		if h := m.headroom(now); h > bestHeadroom {
+			best, bestHeadroom = m, h
+		}
+	}
+	if best == nil {
+		return
+	}
+	if !best.known {
+		// Assume an unused credential has the quota of the used ones.
+		if rate.Limit > 0 {
+			bestHeadroom = rate.Limit
+		} else {
+			bestHeadroom = 1
+		}
+		resp.Header.Set(headerRateRemaining, strconv.Itoa(bestHeadroom))
+		return
+	}
+	resp.Header.Set(headerRateLimit, strconv.Itoa(best.rate.Limit))
+	resp.Header.Set(headerRateRemaining, strconv.Itoa(bestHeadroom))
+	if !best.rate.Reset.IsZero() {
+		resp.Header.Set(headerRateReset, strconv.FormatInt(best.rate.Reset.Unix(), 10))
+	}
+}
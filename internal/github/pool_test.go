@@ -0,0 +1,178 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-github/v52/github"
+)
+
+// newPoolTestClient returns a client sending requests through a pool of
+// tokens to a server granting each token the number of requests in
+// remaining. Tokens missing from remaining are rejected.
+func newPoolTestClient(t *testing.T, remaining map[string]int, tokens ...string) (*client, *Pool) {
+	mu := sync.Mutex{}
+	reset := time.Now().Add(time.Hour).Unix()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		n, ok := remaining[token]
+		if !ok {
+			http.Error(w, `{"message":"Bad credentials"}`, http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set(headerRateLimit, "10")
+		w.Header().Set(headerRateReset, strconv.FormatInt(reset, 10))
+		if n == 0 {
+			w.Header().Set(headerRateRemaining, "0")
+			http.Error(w, `{"message":"API rate limit exceeded"}`, http.StatusForbidden)
+			return
+		}
+		remaining[token] = n - 1
+		w.Header().Set(headerRateRemaining, strconv.Itoa(n-1))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"page_number":1}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	creds := []Credential{}
+	for _, token := range tokens {
+		creds = append(creds, Credential{Name: token, Authenticator: &TokenAuthenticator{Token: token}})
+	}
+	pool := NewPool(creds...)
+	c := NewClient(&http.Client{Transport: pool})
+	c.CodeSearchURL, _ = url.Parse(srv.URL + "/api/")
+	c.RetryPolicy = &RetryPolicy{MaxAttempts: 5, Retry: RetryCredentials}
+	return c, pool
+}
+
+func doSearch(c *client) (*Response, error) {
+	req, err := c.NewRequest("GET", "search?q=a", nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(context.TODO(), req, nil)
+}
+
+func TestPoolHeadroom(t *testing.T) {
+	c, pool := newPoolTestClient(t, map[string]int{"a": 3, "b": 8}, "a", "b")
+	for i := 0; i < 8; i++ {
+		_, err := doSearch(c)
+		if err != nil {
+			t.Fatalf("Request %d failed: %v", i, err)
+		}
+	}
+
+	// Both are tried first, then b until it is down to the 2 left of a,
+	// then they alternate.
+	requests := map[string]int{}
+	for _, u := range pool.Usage() {
+		requests[u.Name] = u.Requests
+	}
+	if diff := cmp.Diff(map[string]int{"a": 2, "b": 6}, requests); diff != "" {
+		t.Errorf("Unexpected requests per credential:\n%s", diff)
+	}
+}
+
+func TestPoolRateLimited(t *testing.T) {
+	remaining := map[string]int{"a": 4, "b": 3}
+	c, pool := newPoolTestClient(t, remaining, "a", "b")
+	for i := 0; i < 2; i++ {
+		if _, err := doSearch(c); err != nil {
+			t.Fatal("Request failed:", err)
+		}
+	}
+	// Someone else uses up the quota of a.
+	remaining["a"] = 0
+
+	resp, err := doSearch(c)
+	if err != nil {
+		t.Fatal("Request was not resent with another credential:", err)
+	}
+	if resp.Rate.Remaining == 0 {
+		t.Errorf("Expected rate of the credential with the most headroom, got %s", resp.Rate)
+	}
+
+	usage := pool.Usage()
+	if usage[0].RateLimited != 1 || usage[1].RateLimited != 0 {
+		t.Errorf("Unexpected usage: %v", usage)
+	}
+}
+
+func TestPoolRateLimitedAll(t *testing.T) {
+	c, _ := newPoolTestClient(t, map[string]int{"a": 0, "b": 0}, "a", "b")
+	_, err := doSearch(c)
+	rerr := &github.RateLimitError{}
+	if !errors.As(err, &rerr) {
+		t.Fatalf("Expected rate limit error once all credentials are used up, got %v", err)
+	}
+}
+
+func TestPoolInvalid(t *testing.T) {
+	c, pool := newPoolTestClient(t, map[string]int{"good": 5}, "bad", "good")
+	for i := 0; i < 2; i++ {
+		if _, err := doSearch(c); err != nil {
+			t.Fatal("Request was not resent with a valid credential:", err)
+		}
+	}
+	usage := pool.Usage()
+	if !usage[0].Invalid || usage[0].Requests != 1 || usage[1].Invalid || usage[1].Requests != 2 {
+		t.Errorf("Unexpected usage: %v", usage)
+	}
+
+	c, _ = newPoolTestClient(t, map[string]int{}, "bad")
+	_, err := doSearch(c)
+	if err == nil {
+		t.Fatal("Expected request with the last credential to fail")
+	}
+	_, err = doSearch(c)
+	if !errors.Is(err, ErrNoCredentials) {
+		t.Fatalf("Expected ErrNoCredentials, got %v", err)
+	}
+}
+
+func TestPoolResendLimited(t *testing.T) {
+	c, _ := newPoolTestClient(t, map[string]int{"good": 5}, "bad", "good")
+	c.Limiter = &Limiter{Budget: 10}
+	if _, err := doSearch(c); err != nil {
+		t.Fatal("Request was not resent with a valid credential:", err)
+	}
+	// The rejected attempt counts as well.
+	if n := c.Limiter.Used(); n != 2 {
+		t.Errorf("Expected 2 requests to pass the limiter, got %d", n)
+	}
+
+	c, _ = newPoolTestClient(t, map[string]int{"good": 5}, "bad", "good")
+	c.Limiter = &Limiter{Budget: 1}
+	if _, err := doSearch(c); !errors.Is(err, ErrBudgetExhausted) {
+		t.Errorf("Expected resend to exhaust the budget, got %v", err)
+	}
+}
+
+func TestPoolLimitTransport(t *testing.T) {
+	c, pool := newPoolTestClient(t, map[string]int{"good": 5}, "bad", "good")
+	limiter := &Limiter{Budget: 10}
+	hc := &http.Client{Transport: &LimitTransport{Limiter: limiter, Base: pool}}
+	resp, err := hc.Get(c.CodeSearchURL.String() + "search?q=a")
+	if err != nil {
+		t.Fatal("Request was not resent with a valid credential:", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Error("Unexpected status:", resp.Status)
+	}
+	if n := limiter.Used(); n != 2 {
+		t.Errorf("Expected 2 requests to pass the limiter, got %d", n)
+	}
+}
@@ -0,0 +1,81 @@
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// QueryError is returned by CodeSearchService.Search when code search
+// answered with a 2xx status but rejected the query, e.g. because of a
+// syntax error. Without it such responses look like an empty result.
+type QueryError struct {
+	Query string
+	// Message is the top level error reported by code search, if any.
+	Message string
+	Details []*QueryErrorDetail
+	// RequestId identifies the failed request in GitHub's logs.
+	RequestId string
+}
+
+func (e *QueryError) Error() string {
+	msgs := make([]string, 0, len(e.Details)+1)
+	if e.Message != "" {
+		msgs = append(msgs, e.Message)
+	}
+	for _, d := range e.Details {
+		msgs = append(msgs, d.String())
+	}
+	if len(msgs) == 0 {
+		msgs = append(msgs, "search failed")
+	}
+	return fmt.Sprintf("code search query %q: %s", e.Query, strings.Join(msgs, "; "))
+}
+
+// QueryErrorDetail is a single entry of the query_errors of a response.
+type QueryErrorDetail struct {
+	Message string `json:"message,omitempty"`
+	// Position is the byte offset into the query the error refers to,
+	// or -1 if code search did not report one.
+	Position int `json:"position"`
+}
+
+func (d *QueryErrorDetail) String() string {
+	if d.Position < 0 {
+		return d.Message
+	}
+	return fmt.Sprintf("%s (at position %d)", d.Message, d.Position)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+// Details are either plain strings or objects with a message and an
+// optional position.
+func (d *QueryErrorDetail) UnmarshalJSON(data []byte) error {
+	var msg string
+	if err := json.Unmarshal(data, &msg); err == nil {
+		*d = QueryErrorDetail{Message: msg, Position: -1}
+		return nil
+	}
+
+	var obj struct {
+		Message     string `json:"message"`
+		Description string `json:"description"`
+		Position    *int   `json:"position"`
+		Offset      *int   `json:"offset"`
+	}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+
+	*d = QueryErrorDetail{Message: obj.Message, Position: -1}
+	if d.Message == "" {
+		d.Message = obj.Description
+	}
+	switch {
+	case obj.Position != nil:
+		d.Position = *obj.Position
+	case obj.Offset != nil:
+		d.Position = *obj.Offset
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestQueryErrorDetailDecode(t *testing.T) {
+	result := CodeSearchResult{}
+	err := json.Unmarshal([]byte(`{
+		"failed": true,
+		"query_errors": [
+			"unbalanced parentheses",
+			{"message": "unknown qualifier", "position": 4},
+			{"description": "invalid regex"}
+		]
+	}`), &result)
+	if err != nil {
+		t.Fatal("Decode failed:", err)
+	}
+
+	diff := cmp.Diff(result.QueryErrors, []*QueryErrorDetail{
+		{Message: "unbalanced parentheses", Position: -1},
+		{Message: "unknown qualifier", Position: 4},
+		{Message: "invalid regex", Position: -1},
+	})
+	if diff != "" {
+		t.Fatalf("Decode result diff:\n%s\n", diff)
+	}
+}
+
+func TestSearchQueryError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"failed": true, "error": "bad query", "request_id": "A1", "query_errors": [{"message": "unexpected )", "position": 7}]}`))
+	}))
+	defer srv.Close()
+
+	c := NewClient(nil)
+	c.CodeSearchURL, _ = url.Parse(srv.URL + "/api/")
+
+	result, _, err := c.CodeSearch().Search(context.TODO(), "path:a )", &SearchOptions{})
+	if result != nil {
+		t.Error("Unexpected result for failed query:", result)
+	}
+
+	qerr := &QueryError{}
+	if !errors.As(err, &qerr) {
+		t.Fatalf("Expected QueryError, got %T: %v", err, err)
+	}
+	expected := `code search query "path:a )": bad query; unexpected ) (at position 7)`
+	if qerr.Error() != expected {
+		t.Errorf("Unexpected message:\n%s\nexpected:\n%s", qerr.Error(), expected)
+	}
+	if qerr.RequestId != "A1" {
+		t.Error("Unexpected RequestId:", qerr.RequestId)
+	}
+}
@@ -0,0 +1,116 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/go-github/v52/github"
+)
+
+// Rate represents the rate limit state reported by the X-RateLimit-*
+// headers of a response.
+type Rate struct {
+	// The number of requests per hour the client is currently limited to.
+	Limit int `json:"limit"`
+
+	// The number of remaining requests the client can make this hour.
+	Remaining int `json:"remaining"`
+
+	// The time at which the current rate limit will reset.
+	Reset Timestamp `json:"reset"`
+}
+
+func (r Rate) String() string {
+	return fmt.Sprintf("%d/%d requests remaining, reset at %s", r.Remaining, r.Limit, r.Reset)
+}
+
+// parseRate parses the rate related headers. ok is false if the response
+// did not carry any.
+func parseRate(r *http.Response) (rate Rate, ok bool) {
+	if limit := r.Header.Get(headerRateLimit); limit != "" {
+		rate.Limit, _ = strconv.Atoi(limit)
+		ok = true
+	}
+	if remaining := r.Header.Get(headerRateRemaining); remaining != "" {
+		rate.Remaining, _ = strconv.Atoi(remaining)
+		ok = true
+	}
+	if reset := r.Header.Get(headerRateReset); reset != "" {
+		if v, _ := strconv.ParseInt(reset, 10, 64); v != 0 {
+			rate.Reset = Timestamp{time.Unix(v, 0)}
+		}
+		ok = true
+	}
+	return rate, ok
+}
+
+func (r Rate) toGitHub() github.Rate {
+	return github.Rate{
+		Limit:     r.Limit,
+		Remaining: r.Remaining,
+		Reset:     github.Timestamp{Time: r.Reset.Time},
+	}
+}
+
+// RateLimitWaitError is returned instead of sending a request if the
+// client would have to wait for a rate limit reset which lies beyond the
+// deadline of the request's context.
+type RateLimitWaitError struct {
+	Reset     time.Time
+	Deadline  time.Time
+	Secondary bool
+}
+
+func (e *RateLimitWaitError) Error() string {
+	kind := "primary"
+	if e.Secondary {
+		kind = "secondary"
+	}
+	return fmt.Sprintf("%s rate limit resets at %s, after context deadline %s", kind, e.Reset.Format(time.RFC3339), e.Deadline.Format(time.RFC3339))
+}
+
+// updateRate records the rate state of resp for pacing later requests.
+func (c *client) updateRate(rate Rate) {
+	c.rateMu.Lock()
+	c.rate = rate
+	c.rateMu.Unlock()
+}
+
+// waitRateLimit blocks until neither the secondary nor the primary rate
+// limit forbids sending a request. It fails fast if the wait would exceed
+// the deadline of ctx.
+func (c *client) waitRateLimit(ctx context.Context) error {
+	c.rateMu.Lock()
+	until := c.secondaryRateLimitReset
+	secondary := true
+	if c.rate.Limit > 0 && c.rate.Remaining == 0 && c.rate.Reset.After(until) {
+		until = c.rate.Reset.Time
+		secondary = false
+	}
+	c.rateMu.Unlock()
+
+	wait := time.Until(until)
+	if wait <= 0 {
+		return nil
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && deadline.Before(until) {
+		return &RateLimitWaitError{
+			Reset:     until,
+			Deadline:  deadline,
+			Secondary: secondary,
+		}
+	}
+
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,89 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newRateLimitTestClient(t *testing.T, h http.HandlerFunc) *client {
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+
+	c := NewClient(nil)
+	c.CodeSearchURL, _ = url.Parse(srv.URL + "/api/")
+	return c
+}
+
+func TestResponseRate(t *testing.T) {
+	reset := time.Now().Add(time.Hour).Truncate(time.Second)
+	c := newRateLimitTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(headerRateLimit, "60")
+		w.Header().Set(headerRateRemaining, "59")
+		w.Header().Set(headerRateReset, strconv.FormatInt(reset.Unix(), 10))
+		w.Write([]byte(`{}`))
+	})
+
+	_, resp, err := c.CodeSearch().Search(context.TODO(), "foo", &SearchOptions{})
+	if err != nil {
+		t.Fatal("Search failed:", err)
+	}
+	if resp.Rate.Limit != 60 || resp.Rate.Remaining != 59 || !resp.Rate.Reset.Time.Equal(reset) {
+		t.Fatal("Unexpected Rate:", resp.Rate)
+	}
+}
+
+func TestWaitSecondaryRateLimit(t *testing.T) {
+	c := newRateLimitTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	})
+
+	wait := 50 * time.Millisecond
+	c.secondaryRateLimitReset = time.Now().Add(wait)
+
+	start := time.Now()
+	_, _, err := c.CodeSearch().Search(context.TODO(), "foo", &SearchOptions{})
+	if err != nil {
+		t.Fatal("Search failed:", err)
+	}
+	if elapsed := time.Since(start); elapsed < wait {
+		t.Fatal("Request was sent before the secondary rate limit reset:", elapsed)
+	}
+}
+
+func TestRateLimitDeadline(t *testing.T) {
+	var hits int32
+	reset := time.Now().Add(time.Hour)
+	c := newRateLimitTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set(headerRateLimit, "60")
+		w.Header().Set(headerRateRemaining, "0")
+		w.Header().Set(headerRateReset, strconv.FormatInt(reset.Unix(), 10))
+		w.Write([]byte(`{}`))
+	})
+
+	_, _, err := c.CodeSearch().Search(context.TODO(), "foo", &SearchOptions{})
+	if err != nil {
+		t.Fatal("Search failed:", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	_, _, err = c.CodeSearch().Search(ctx, "foo", &SearchOptions{})
+	werr := &RateLimitWaitError{}
+	if !errors.As(err, &werr) {
+		t.Fatalf("Expected RateLimitWaitError, got %T: %v", err, err)
+	}
+	if werr.Secondary {
+		t.Error("Primary rate limit reported as secondary")
+	}
+	if n := atomic.LoadInt32(&hits); n != 1 {
+		t.Fatal("Request sent despite exhausted rate limit, hits:", n)
+	}
+}
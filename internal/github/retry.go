@@ -0,0 +1,189 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/google/go-github/v52/github"
+)
+
+const headerRequestId = "X-GitHub-Request-Id"
+
+// RetryClass selects a class of failures a RetryPolicy retries.
+type RetryClass uint
+
+const (
+	// RetryServerErrors retries responses with a 5xx status.
+	RetryServerErrors RetryClass = 1 << iota
+	// RetryNetworkErrors retries transient transport failures: connection
+	// resets and refusals, unexpected EOFs and timeouts.
+	RetryNetworkErrors
+	// RetryAccepted polls requests answered with 202 Accepted until the
+	// result is ready.
+	RetryAccepted
+	// RetryRateLimits retries after primary and secondary rate limit
+	// errors. The client waits for the reset before sending again.
+	RetryRateLimits
+	// RetryCredentials resends requests a Pool rejected or rate limited
+	// with one credential right away, the Pool sends them with another.
+	RetryCredentials
+
+	RetryAll = RetryServerErrors | RetryNetworkErrors | RetryAccepted | RetryRateLimits | RetryCredentials
+)
+
+// RetryPolicy configures how often and when the client repeats a failed
+// request. The delay before attempt n+1 is InitialBackoff*Multiplier^(n-1),
+// capped at MaxBackoff and randomized by ±Jitter.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first.
+	// Values below 2 disable retries.
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	// Jitter is the fraction of the backoff by which it is randomized.
+	Jitter float64
+	// AcceptedPollInterval is the delay between polls of a request
+	// answered with 202 Accepted.
+	AcceptedPollInterval time.Duration
+	Retry                RetryClass
+}
+
+// DefaultRetryPolicy is used by clients created with NewClient.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:          5,
+	InitialBackoff:       time.Second,
+	MaxBackoff:           30 * time.Second,
+	Multiplier:           2,
+	Jitter:               0.2,
+	AcceptedPollInterval: 2 * time.Second,
+	Retry:                RetryAll,
+}
+
+// classify returns the RetryClass err belongs to, or 0 if it must not be
+// retried.
+func classify(err error) RetryClass {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrNoCredentials) {
+		return 0
+	}
+
+	var (
+		aerr   *AcceptedError
+		rerr   *github.ErrorResponse
+		rlerr  *github.RateLimitError
+		abuerr *github.AbuseRateLimitError
+		nerr   net.Error
+	)
+	switch {
+	case errors.Is(err, errResend):
+		return RetryCredentials
+	case errors.As(err, &aerr):
+		return RetryAccepted
+	case errors.As(err, &rlerr), errors.As(err, &abuerr):
+		return RetryRateLimits
+	case errors.As(err, &rerr):
+		if rerr.Response != nil && rerr.Response.StatusCode >= 500 {
+			return RetryServerErrors
+		}
+		return 0
+	case errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.ECONNREFUSED):
+		return RetryNetworkErrors
+	// Every *url.Error is a net.Error, so only timeouts are transient.
+	case errors.As(err, &nerr) && nerr.Timeout():
+		return RetryNetworkErrors
+	}
+	return 0
+}
+
+// backoff returns the delay before the attempt following attempt.
+func (p *RetryPolicy) backoff(attempt int, class RetryClass) time.Duration {
+	if class == RetryAccepted && p.AcceptedPollInterval > 0 {
+		return p.AcceptedPollInterval
+	}
+	if class == RetryCredentials {
+		return 0
+	}
+
+	d := float64(p.InitialBackoff) * math.Pow(p.Multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		d += d * p.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(d)
+}
+
+// doWithRetry sends req through BareDo until it succeeds, fails with an
+// error the policy does not retry or runs out of attempts.
+func (c *client) doWithRetry(ctx context.Context, req *http.Request) (*Response, error) {
+	p := c.RetryPolicy
+	if p == nil {
+		p = &RetryPolicy{MaxAttempts: 1}
+	}
+	maxAttempts := p.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	for attempt := 1; ; attempt++ {
+		attemptReq := req
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attemptReq = req.Clone(ctx)
+			attemptReq.Body = body
+		}
+
+		resp, err := c.BareDo(ctx, attemptReq)
+		logAttempt(req, resp, err, attempt, maxAttempts)
+		if err == nil {
+			return resp, nil
+		}
+
+		class := classify(err)
+		if attempt >= maxAttempts || class&p.Retry == 0 {
+			return resp, err
+		}
+
+		delay := p.backoff(attempt, class)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return resp, err
+		}
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return resp, ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+func logAttempt(req *http.Request, resp *Response, err error, attempt, maxAttempts int) {
+	requestId := "-"
+	status := "-"
+	if resp != nil && resp.Response != nil {
+		if id := resp.Header.Get(headerRequestId); id != "" {
+			requestId = id
+		}
+		status = resp.Status
+	}
+
+	u := *req.URL
+	if err != nil {
+		log.Printf("%s %s: attempt %d/%d (request id %s) failed: %v\n", req.Method, sanitizeURL(&u), attempt, maxAttempts, requestId, err)
+		return
+	}
+	log.Printf("%s %s: attempt %d/%d (request id %s): %s\n", req.Method, sanitizeURL(&u), attempt, maxAttempts, requestId, status)
+}
@@ -0,0 +1,142 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v52/github"
+)
+
+var testRetryPolicy = RetryPolicy{
+	MaxAttempts:          3,
+	InitialBackoff:       time.Millisecond,
+	MaxBackoff:           5 * time.Millisecond,
+	Multiplier:           2,
+	Jitter:               0.5,
+	AcceptedPollInterval: time.Millisecond,
+	Retry:                RetryAll,
+}
+
+// failingHandler answers the first fails requests with status and all
+// following ones with an empty result.
+func failingHandler(hits *int32, fails int32, status int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(headerRequestId, "ID")
+		if atomic.AddInt32(hits, 1) <= fails {
+			w.WriteHeader(status)
+			return
+		}
+		w.Write([]byte(`{"page_number": 1}`))
+	}
+}
+
+func TestRetry(t *testing.T) {
+	for _, status := range []int{http.StatusBadGateway, http.StatusAccepted} {
+		var hits int32
+		c := newRateLimitTestClient(t, failingHandler(&hits, 2, status))
+		policy := testRetryPolicy
+		c.RetryPolicy = &policy
+
+		result, _, err := c.CodeSearch().Search(context.TODO(), "foo", &SearchOptions{})
+		if err != nil {
+			t.Fatalf("Search with status %d failed: %v", status, err)
+		}
+		if result.PageNumber != 1 {
+			t.Errorf("Unexpected result after status %d: %+v", status, result)
+		}
+		if n := atomic.LoadInt32(&hits); n != 3 {
+			t.Errorf("Expected 3 attempts for status %d, got %d", status, n)
+		}
+	}
+}
+
+func TestRetryMaxAttempts(t *testing.T) {
+	var hits int32
+	c := newRateLimitTestClient(t, failingHandler(&hits, 5, http.StatusInternalServerError))
+	policy := testRetryPolicy
+	c.RetryPolicy = &policy
+
+	_, resp, err := c.CodeSearch().Search(context.TODO(), "foo", &SearchOptions{})
+	if _, ok := err.(*github.ErrorResponse); !ok {
+		t.Fatalf("Expected ErrorResponse, got %T: %v", err, err)
+	}
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Error("Unexpected status:", resp.StatusCode)
+	}
+	if n := atomic.LoadInt32(&hits); n != 3 {
+		t.Error("Expected 3 attempts, got", n)
+	}
+}
+
+func TestRetryClasses(t *testing.T) {
+	tests := []struct {
+		status   int
+		retry    RetryClass
+		attempts int32
+	}{
+		{http.StatusNotFound, RetryAll, 1},
+		{http.StatusBadGateway, RetryAccepted, 1},
+		{http.StatusAccepted, RetryServerErrors, 1},
+		{http.StatusAccepted, RetryAccepted, 2},
+	}
+
+	for _, test := range tests {
+		var hits int32
+		c := newRateLimitTestClient(t, failingHandler(&hits, 1, test.status))
+		policy := testRetryPolicy
+		policy.Retry = test.retry
+		c.RetryPolicy = &policy
+
+		c.CodeSearch().Search(context.TODO(), "foo", &SearchOptions{})
+		if n := atomic.LoadInt32(&hits); n != test.attempts {
+			t.Errorf("Status %d with classes %b: expected %d attempts, got %d", test.status, test.retry, test.attempts, n)
+		}
+	}
+}
+
+// errTransport fails every request with err.
+type errTransport struct {
+	hits *int32
+	err  error
+}
+
+func (t errTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(t.hits, 1)
+	return nil, t.err
+}
+
+func TestRetryNetworkErrors(t *testing.T) {
+	tests := []struct {
+		err      error
+		attempts int32
+	}{
+		{errors.New("x509: certificate signed by unknown authority"), 1},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, 3},
+		{&net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}, 3},
+		{io.ErrUnexpectedEOF, 3},
+		{ErrNoCredentials, 1},
+	}
+
+	for _, test := range tests {
+		var hits int32
+		c := NewClient(&http.Client{Transport: errTransport{hits: &hits, err: test.err}})
+		policy := testRetryPolicy
+		c.RetryPolicy = &policy
+
+		_, _, err := c.CodeSearch().Search(context.TODO(), "foo", &SearchOptions{})
+		if err == nil {
+			t.Fatalf("Expected %v to fail the search", test.err)
+		}
+		if n := atomic.LoadInt32(&hits); n != test.attempts {
+			t.Errorf("%v: expected %d attempts, got %d", test.err, test.attempts, n)
+		}
+	}
+}
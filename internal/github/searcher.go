@@ -0,0 +1,61 @@
+package github
+
+import (
+	"context"
+
+	"github.com/abergmeier/knollledge/internal/search"
+)
+
+// PageSearcher is the page based search API of CodeSearchService. Other
+// backends returning the results of cs.github.com implement it too.
+type PageSearcher interface {
+	Search(ctx context.Context, query string, opts *SearchOptions) (*CodeSearchResult, *Response, error)
+}
+
+// Searcher returns a search.Searcher for cs.github.com.
+func (s *CodeSearchService) Searcher() search.Searcher {
+	return NewSearcher(s, search.BackendCodeSearch)
+}
+
+// NewSearcher returns a search.Searcher running queries through s.
+// backend names the backend s searches.
+func NewSearcher(s PageSearcher, backend string) search.Searcher {
+	return &searcher{s: s, backend: backend}
+}
+
+type searcher struct {
+	s       PageSearcher
+	backend string
+}
+
+func (a *searcher) Backend() string {
+	return a.backend
+}
+
+func (a *searcher) Search(ctx context.Context, query string, cursor search.Cursor) (*search.Page, error) {
+	csr, _, err := a.s.Search(ctx, query, &SearchOptions{
+		ListOptions: ListOptions{
+			Page:      cursor.Page,
+			PageToken: cursor.Token,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	p := &search.Page{
+		Cursor:       cursor,
+		Number:       csr.PageNumber,
+		TotalPages:   csr.TotalPages,
+		TotalResults: csr.ResultsCount,
+		Results:      csr.Results,
+		Facets:       csr.Facets,
+	}
+	if next := csr.NextPage(); next != nil {
+		p.Next = &search.Cursor{
+			Page:  next.Page,
+			Token: next.PageToken,
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,95 @@
+package github
+
+import (
+	"context"
+	"testing"
+
+	"github.com/abergmeier/knollledge/internal/search"
+	"github.com/google/go-cmp/cmp"
+)
+
+type pageSearcherFunc func(ctx context.Context, query string, opts *SearchOptions) (*CodeSearchResult, *Response, error)
+
+func (f pageSearcherFunc) Search(ctx context.Context, query string, opts *SearchOptions) (*CodeSearchResult, *Response, error) {
+	return f(ctx, query, opts)
+}
+
+func TestSearcher(t *testing.T) {
+	s := NewSearcher(pageSearcherFunc(func(ctx context.Context, query string, opts *SearchOptions) (*CodeSearchResult, *Response, error) {
+		if query != "path:**/go.mod" {
+			t.Error("Unexpected query:", query)
+		}
+		pn := uint(opts.Page)
+		if pn == 0 {
+			pn = 1
+		}
+		return &CodeSearchResult{
+			PageNumber:   pn,
+			TotalPages:   2,
+			ResultsCount: 150,
+			PageToken:    "token",
+			Results: []*CodeResult{{
+				Path:        "go.mod",
+				RepoName:    "a/b",
+				Snippets:    []*Snippet{{Lines: []string{"module a"}, StartLine: 1}},
+				Matches:     []*Match{{Start: 0, End: 6}},
+				ScoringInfo: &ScoringInfo{Score: 1.5},
+			}},
+			Facets: []*FacetGroup{{
+				Kind:   FacetKindLanguages,
+				Facets: []*Facet{{Name: "Go", Query: "language:Go", Occurrences: 1}},
+			}},
+		}, nil, nil
+	}), search.BackendCodeSearch)
+
+	if b := search.BackendOf(s); b != search.BackendCodeSearch {
+		t.Error("Unexpected backend:", b)
+	}
+
+	first, err := s.Search(context.TODO(), "path:**/go.mod", search.Cursor{})
+	if err != nil {
+		t.Fatal("Search failed:", err)
+	}
+	diff := cmp.Diff(first, &search.Page{
+		Next:         &search.Cursor{Page: 2, Token: "token"},
+		Number:       1,
+		TotalPages:   2,
+		TotalResults: 150,
+		Results: []*search.Result{{
+			Path:        "go.mod",
+			RepoName:    "a/b",
+			Snippets:    []*search.Snippet{{Lines: []string{"module a"}, StartLine: 1}},
+			Matches:     []*search.Match{{Start: 0, End: 6}},
+			ScoringInfo: &search.ScoringInfo{Score: 1.5},
+		}},
+		Facets: []*search.FacetGroup{{
+			Kind:   search.FacetKindLanguages,
+			Facets: []*search.Facet{{Name: "Go", Query: "language:Go", Occurrences: 1}},
+		}},
+	})
+	if diff != "" {
+		t.Errorf("Unexpected first page:\n%s\n", diff)
+	}
+
+	last, err := s.Search(context.TODO(), "path:**/go.mod", *first.Next)
+	if err != nil {
+		t.Fatal("Search failed:", err)
+	}
+	if last.Next != nil || last.Cursor != *first.Next {
+		t.Errorf("Unexpected cursors of last page: %+v -> %+v", last.Cursor, last.Next)
+	}
+}
+
+func TestSearcherUnnumberedPage(t *testing.T) {
+	s := NewSearcher(pageSearcherFunc(func(ctx context.Context, query string, opts *SearchOptions) (*CodeSearchResult, *Response, error) {
+		return &CodeSearchResult{TotalPages: 3}, nil, nil
+	}), search.BackendCodeSearch)
+
+	page, err := s.Search(context.TODO(), "class", search.Cursor{})
+	if err != nil {
+		t.Fatal("Search failed:", err)
+	}
+	if page.Next != nil {
+		t.Errorf("Expected page without number to be the last, got next %+v", page.Next)
+	}
+}
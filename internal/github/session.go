@@ -0,0 +1,83 @@
+package github
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrSessionExpired is matched by errors.Is for all errors caused by an
+// expired or missing login session.
+var ErrSessionExpired = errors.New("code search session expired")
+
+// SessionExpiredError is returned when code search answers with a login
+// page instead of JSON. This happens when the session cookies are expired
+// or missing, and will keep happening for every further request.
+type SessionExpiredError struct {
+	Response *http.Response
+	// Login is the login page the request was redirected to, if any.
+	Login string
+	// ContentType is the media type of the response.
+	ContentType string
+}
+
+func (e *SessionExpiredError) Error() string {
+	if e.Login != "" {
+		return fmt.Sprintf("%s: %s %s redirected to login page %s", ErrSessionExpired, e.Response.Request.Method, sanitizeURL(originalURL(e.Response)), e.Login)
+	}
+	return fmt.Sprintf("%s: %s %s returned %s instead of JSON", ErrSessionExpired, e.Response.Request.Method, sanitizeURL(originalURL(e.Response)), e.ContentType)
+}
+
+// Is returns whether target is ErrSessionExpired.
+func (e *SessionExpiredError) Is(target error) bool {
+	return target == ErrSessionExpired
+}
+
+// checkSession detects responses which are login pages rather than API
+// results. Code search redirects to the login page with a 200, so this
+// has to look at where the request ended up and what it returned.
+func checkSession(r *http.Response) error {
+	if r.Request == nil {
+		return nil
+	}
+
+	if loc := r.Header.Get("Location"); r.StatusCode >= 300 && r.StatusCode <= 399 && loc != "" {
+		if u, err := r.Request.URL.Parse(loc); err == nil && isLoginURL(u) {
+			return &SessionExpiredError{Response: r, Login: u.String()}
+		}
+	}
+
+	if isLoginURL(r.Request.URL) {
+		return &SessionExpiredError{Response: r, Login: r.Request.URL.String()}
+	}
+
+	if r.StatusCode < 200 || r.StatusCode > 299 {
+		return nil
+	}
+	if mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err == nil && mt == "text/html" {
+		return &SessionExpiredError{Response: r, ContentType: mt}
+	}
+	return nil
+}
+
+func isLoginURL(u *url.URL) bool {
+	for _, p := range []string{"/login", "/session", "/sessions"} {
+		if u.Path == p || strings.HasPrefix(u.Path, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// originalURL returns the URL of the request before any redirects.
+func originalURL(r *http.Response) *url.URL {
+	req := r.Request
+	for req.Response != nil && req.Response.Request != nil {
+		req = req.Response.Request
+	}
+	u := *req.URL
+	return &u
+}
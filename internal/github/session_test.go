@@ -0,0 +1,63 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSessionExpired(t *testing.T) {
+	tests := map[string]http.HandlerFunc{
+		"login redirect": func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/login" {
+				w.Header().Set("Content-Type", "text/html; charset=utf-8")
+				w.Write([]byte("<html>Sign in to GitHub</html>"))
+				return
+			}
+			http.Redirect(w, r, "/login?return_to=%2Fsearch", http.StatusFound)
+		},
+		"html page": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte("<html>Sign in to GitHub</html>"))
+		},
+	}
+
+	for name, h := range tests {
+		var hits int32
+		c := newRateLimitTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/login" {
+				atomic.AddInt32(&hits, 1)
+			}
+			h(w, r)
+		})
+		policy := testRetryPolicy
+		c.RetryPolicy = &policy
+
+		_, _, err := c.CodeSearch().Search(context.TODO(), "foo", &SearchOptions{})
+		if !errors.Is(err, ErrSessionExpired) {
+			t.Fatalf("%s: expected ErrSessionExpired, got %T: %v", name, err, err)
+		}
+		if !strings.Contains(err.Error(), "/api/search?q=foo") {
+			t.Errorf("%s: error does not name the request: %s", name, err)
+		}
+		if n := atomic.LoadInt32(&hits); n != 1 {
+			t.Errorf("%s: expired session was retried, attempts: %d", name, n)
+		}
+	}
+}
+
+func TestErrorPageIsNotSessionExpired(t *testing.T) {
+	c := newRateLimitTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("<html>Not Found</html>"))
+	})
+
+	_, _, err := c.CodeSearch().Search(context.TODO(), "foo", &SearchOptions{})
+	if err == nil || errors.Is(err, ErrSessionExpired) {
+		t.Fatal("Expected a plain error response, got:", err)
+	}
+}
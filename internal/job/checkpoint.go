@@ -0,0 +1,100 @@
+package job
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/abergmeier/knollledge/internal/search"
+)
+
+const checkpointVersion = 1
+
+// Checkpoint is the state of a CodeSearch which stopped before fetching
+// all of its pages. A later run with the same query and options resumes
+// from Next instead of starting over.
+type Checkpoint struct {
+	Version int `json:"version"`
+	// Key is the Fingerprint of the job the checkpoint belongs to.
+	Key string `json:"key"`
+	// Next is the cursor of the first page not fetched yet.
+	Next search.Cursor `json:"next"`
+	// Result holds the results of the pages fetched so far.
+	Result *Result `json:"result"`
+}
+
+// CheckpointStore persists Checkpoints between runs.
+type CheckpointStore interface {
+	// Load returns the checkpoint of cs, or nil if there is none.
+	Load(cs *CodeSearch) (*Checkpoint, error)
+	Save(cs *CodeSearch, cp *Checkpoint) error
+	Remove(cs *CodeSearch) error
+}
+
+// CheckpointDir stores checkpoints as JSON files in a directory, usually
+// next to the job output.
+type CheckpointDir string
+
+var _ CheckpointStore = CheckpointDir("")
+
+func (d CheckpointDir) path(cs *CodeSearch) string {
+	return filepath.Join(string(d), cs.Fingerprint()+".checkpoint.json")
+}
+
+// Load returns the checkpoint of cs. Checkpoints of a different query,
+// different options or an older format are discarded.
+func (d CheckpointDir) Load(cs *CodeSearch) (*Checkpoint, error) {
+	data, err := os.ReadFile(d.path(cs))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cp := &Checkpoint{}
+	err = json.Unmarshal(data, cp)
+	if err != nil || cp.Version != checkpointVersion || cp.Key != cs.Fingerprint() || cp.Result == nil {
+		return nil, d.Remove(cs)
+	}
+	return cp, nil
+}
+
+// Save writes cp atomically, so an interrupted run never leaves a
+// truncated checkpoint behind.
+func (d CheckpointDir) Save(cs *CodeSearch, cp *Checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(d.path(cs), data)
+}
+
+// writeFileAtomic writes data to a temporary file next to p and renames
+// it to p.
+func writeFileAtomic(p string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(p), filepath.Base(p)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+func (d CheckpointDir) Remove(cs *CodeSearch) error {
+	err := os.Remove(d.path(cs))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
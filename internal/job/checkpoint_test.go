@@ -0,0 +1,92 @@
+package job
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/abergmeier/knollledge/internal/search"
+	"github.com/google/go-cmp/cmp"
+)
+
+// recordCursors wraps s and records the page of every requested cursor.
+func recordCursors(s search.Searcher, pages *[]int) search.Searcher {
+	return search.SearcherFunc(func(ctx context.Context, query string, cursor search.Cursor) (*search.Page, error) {
+		*pages = append(*pages, cursor.Page)
+		return s.Search(ctx, query, cursor)
+	})
+}
+
+func TestCheckpointResume(t *testing.T) {
+	dir := CheckpointDir(t.TempDir())
+	requested := []int{}
+	cs := CodeSearch{
+		Query:         "path:**.java class",
+		MaxPageNumber: 5,
+		Searcher:      recordCursors(pagedSearcher(t, 5, 3), &requested),
+		Checkpoints:   dir,
+	}
+
+	_, err := cs.Run(context.TODO())
+	if err == nil {
+		t.Fatal("Expected first run to fail at page 3")
+	}
+	if _, err := os.Stat(dir.path(&cs)); err != nil {
+		t.Fatal("No checkpoint written:", err)
+	}
+
+	cs.Searcher = recordCursors(pagedSearcher(t, 5, 0), &requested)
+	res, err := cs.Run(context.TODO())
+	if err != nil {
+		t.Fatal("Resumed run failed:", err)
+	}
+
+	if diff := cmp.Diff(requested, []int{0, 2, 3, 3, 4, 5}); diff != "" {
+		t.Errorf("Unexpected pages requested:\n%s\n", diff)
+	}
+	if diff := cmp.Diff(res.Pages, []uint{1, 2, 3, 4, 5}); diff != "" {
+		t.Errorf("Unexpected pages in result:\n%s\n", diff)
+	}
+	if len(res.Results) != 6 {
+		t.Error("Results of resumed run not merged:", len(res.Results))
+	}
+	if java := res.Facets[0].Facets[0]; java.Occurrences != 5 {
+		t.Error("Facets of resumed run not merged:", java.Occurrences)
+	}
+	if res.Stop != StopExhausted || res.Error != "" {
+		t.Errorf("Unexpected stop %s (%s)", res.Stop, res.Error)
+	}
+	if _, err := os.Stat(dir.path(&cs)); !os.IsNotExist(err) {
+		t.Error("Checkpoint not removed after completion:", err)
+	}
+}
+
+func TestCheckpointInvalidated(t *testing.T) {
+	dir := CheckpointDir(t.TempDir())
+	cs := CodeSearch{
+		Query:         "path:**.java class",
+		MaxPageNumber: 5,
+		Searcher:      pagedSearcher(t, 5, 3),
+		Checkpoints:   dir,
+	}
+	cs.Run(context.TODO())
+
+	cs.MaxPageNumber = 4
+	cp, err := dir.Load(&cs)
+	if err != nil {
+		t.Fatal("Load failed:", err)
+	}
+	if cp != nil {
+		t.Fatal("Checkpoint survived change of max_page:", cp)
+	}
+
+	requested := []int{}
+	cs.Searcher = recordCursors(pagedSearcher(t, 5, 0), &requested)
+	_, err = cs.Run(context.TODO())
+	if err != nil {
+		t.Fatal("Run failed:", err)
+	}
+	if requested[0] != 0 {
+		t.Error("Run did not start over:", requested)
+	}
+}
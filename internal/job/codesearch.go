@@ -2,52 +2,223 @@ package job
 
 import (
 	"context"
-	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"strings"
 
 	"github.com/abergmeier/knollledge/internal/github"
+	"github.com/abergmeier/knollledge/internal/query"
+	"github.com/abergmeier/knollledge/internal/search"
 )
 
 type CodeSearch struct {
 	Query         string `json:"query"`
 	MaxPageNumber int    `json:"max_page"`
+
+	// Preset names a predefined query to run instead of Query, with
+	// QueryPrefix prepended. It is expanded by config.Resolve.
+	Preset      string `json:"preset,omitempty"`
+	QueryPrefix string `json:"query_prefix,omitempty"`
+
+	// Scope restricts the query to organizations, repositories or users.
+	// Jobs with a Scope run through Expand, FanOut forces one job per
+	// scope entry.
+	Scope  *Scope `json:"scope,omitempty"`
+	FanOut bool   `json:"fan_out,omitempty"`
+	// Label names the scope entries an expanded job covers. It is copied
+	// to the result and the manifest but is not part of the Fingerprint.
+	Label string `json:"label,omitempty"`
+
+	// Searcher runs the query. Defaults to an unauthenticated
+	// cs.github.com client.
+	Searcher search.Searcher `json:"-"`
+	// Checkpoints, if set, records progress after every page, so a run
+	// which got interrupted resumes where it stopped.
+	Checkpoints CheckpointStore `json:"-"`
 }
 
-func (cs *CodeSearch) Hash() string {
-	hash := sha1.New()
-	bs := hash.Sum([]byte(cs.Query))
-	return fmt.Sprintf("%x", bs)
+var _ Job = (*CodeSearch)(nil)
+
+// fingerprintVersion is bumped whenever the output of jobs changes in a
+// way that makes earlier outputs and checkpoints unusable.
+const fingerprintVersion = 1
+
+// Fingerprint identifies the output of cs. It is derived from the query
+// and every option changing the output, including the search backend, and
+// carries the version of the scheme, e.g. "v1-3f2a…". Output files,
+// checkpoints and the manifest are keyed by it.
+//
+// Label is left out on purpose: it only names the job in reports and
+// results and does not change what is fetched. Jobs differing in Label
+// alone share one output, and its manifest entry carries the Label of the
+// last run.
+func (cs *CodeSearch) Fingerprint() string {
+	data, _ := json.Marshal(struct {
+		Query         string `json:"query"`
+		MaxPageNumber int    `json:"max_page"`
+		Backend       string `json:"backend,omitempty"`
+	}{
+		Query:         cs.Query,
+		MaxPageNumber: cs.MaxPageNumber,
+		Backend:       cs.backend(),
+	})
+	return fmt.Sprintf("v%d-%x", fingerprintVersion, sha256.Sum256(data))
 }
 
-func (cs *CodeSearch) mustRun(ctx context.Context) *github.CodeSearchResult {
-	c := github.NewClient(nil)
-	return cs.mustRunWithClient(ctx, c)
+// backend returns the name of the backend cs searches.
+func (cs *CodeSearch) backend() string {
+	if cs.Searcher == nil {
+		return search.BackendCodeSearch
+	}
+	return search.BackendOf(cs.Searcher)
 }
 
-func (cs CodeSearch) mustRunWithClient(ctx context.Context, c github.Client) *github.CodeSearchResult {
+// Run runs the code search. If it fails, the partial result of the pages
+// fetched so far is returned along with an *Error.
+func (cs *CodeSearch) Run(ctx context.Context) (*Result, error) {
+	if cs.Scope != nil {
+		return nil, cs.error(errors.New("scope not expanded"))
+	}
+	if cs.MaxPageNumber < 0 {
+		return nil, cs.error(fmt.Errorf("max_page is negative: %d", cs.MaxPageNumber))
+	}
+	s := cs.Searcher
+	if s == nil {
+		s = github.NewClient(nil).CodeSearch().Searcher()
+	}
+	return cs.run(ctx, s)
+}
 
-	codeSearch := c.CodeSearch()
-	csr, _, err := codeSearch.Search(ctx, cs.Query, &github.SearchOptions{})
-	if err != nil {
-		panic(err)
+// run fetches pages of the query until they are exhausted, MaxPageNumber
+// is reached or an error occurs. The results of all pages are merged. On
+// error the result covers the pages fetched so far.
+func (cs *CodeSearch) run(ctx context.Context, s search.Searcher) (*Result, error) {
+	res := &Result{
+		Query: cs.Query,
+		Label: cs.Label,
+	}
+	facets := facetAggregate{}
+	seen := map[resultKey]bool{}
+	cursor := search.Cursor{}
+
+	if cs.Checkpoints != nil {
+		cp, err := cs.Checkpoints.Load(cs)
+		if err != nil {
+			return nil, cs.error(fmt.Errorf("loading checkpoint: %w", err))
+		}
+		if cp != nil {
+			log.Printf("Resuming job %s at page %d\n", cs.Fingerprint(), cp.Next.Page)
+			res = cp.Result
+			res.Stop = ""
+			res.Error = ""
+			facets.add(res.Facets)
+			for _, r := range res.Results {
+				seen[keyOf(r)] = true
+			}
+			cursor = cp.Next
+		}
+	}
+
+	for {
+		page, err := s.Search(ctx, cs.Query, cursor)
+		if err != nil {
+			res.Stop = StopError
+			if errors.Is(err, github.ErrBudgetExhausted) {
+				res.Stop = StopBudget
+			}
+			res.Error = err.Error()
+			res.Facets = facets.groups()
+			return res, cs.error(err)
+		}
+
+		res.Pages = append(res.Pages, page.Number)
+		res.TotalPages = page.TotalPages
+		res.TotalResults = page.TotalResults
+		for _, r := range page.Results {
+			k := keyOf(r)
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+			res.Results = append(res.Results, r)
+		}
+		facets.add(page.Facets)
+
+		if page.Next == nil {
+			res.Stop = StopExhausted
+			break
+		}
+		if page.Number >= uint(cs.MaxPageNumber) {
+			res.Stop = StopMaxPage
+			break
+		}
+		cursor = *page.Next
+		cs.checkpoint(cursor, res, &facets)
 	}
 
-	pn := csr.PageNumber
-	for pn < csr.TotalPages && pn < uint(cs.MaxPageNumber) {
-		csr, _, err = codeSearch.Search(ctx, cs.Query, &github.SearchOptions{
-			ListOptions: github.ListOptions{
-				Page:      int(pn + 1),
-				PageToken: csr.PageToken,
-			},
-		})
+	res.Facets = facets.groups()
+	if cs.Checkpoints != nil {
+		err := cs.Checkpoints.Remove(cs)
 		if err != nil {
-			panic(err)
+			log.Printf("Removing checkpoint of job %s failed: %s\n", cs.Fingerprint(), err)
 		}
 	}
-	return csr
+	return res, nil
+}
+
+// checkpoint records that the pages up to next have been fetched. Failing
+// to do so only costs progress on an interrupted run, so it is logged
+// instead of failing the job.
+func (cs *CodeSearch) checkpoint(next search.Cursor, res *Result, facets *facetAggregate) {
+	if cs.Checkpoints == nil {
+		return
+	}
+
+	res.Facets = facets.groups()
+	err := cs.Checkpoints.Save(cs, &Checkpoint{
+		Version: checkpointVersion,
+		Key:     cs.Fingerprint(),
+		Next:    next,
+		Result:  res,
+	})
+	if err != nil {
+		log.Printf("Saving checkpoint of job %s failed: %s\n", cs.Fingerprint(), err)
+	}
+}
+
+// resultKey identifies a file version across pages.
+type resultKey struct {
+	repo string
+	path string
+	sha  string
+}
+
+func keyOf(r *search.Result) resultKey {
+	repo := r.RepoName
+	if repo == "" {
+		repo = fmt.Sprint(r.RepoId)
+	}
+	return resultKey{
+		repo: repo,
+		path: r.Path,
+		sha:  r.Sha,
+	}
+}
+
+func (cs *CodeSearch) error(err error) *Error {
+	return &Error{
+		Job:   cs.Fingerprint(),
+		Query: cs.Query,
+		Err:   err,
+	}
 }
 
-type MakeCodeSearchFunc func(queryPrefix string) CodeSearch
+// MakeCodeSearchFunc returns the job of a preset restricted by
+// queryPrefix. It fails if queryPrefix does not parse.
+type MakeCodeSearchFunc func(queryPrefix string) (CodeSearch, error)
 
 var (
 	_ MakeCodeSearchFunc = MakeBazelPackageCodeSearch
@@ -62,48 +233,79 @@ var (
 	_ MakeCodeSearchFunc = MakeTerraformGKECluster
 )
 
-func MakeBazelPackageCodeSearch(queryPrefix string) CodeSearch {
-	return makePrefixedCodeSearch(queryPrefix, "path:**/BUILD OR path:**/BUILD.bazel")
+func MakeBazelPackageCodeSearch(queryPrefix string) (CodeSearch, error) {
+	return makePrefixedCodeSearch(queryPrefix, query.OrOf(query.Path("**/BUILD"), query.Path("**/BUILD.bazel")))
+}
+
+func MakeBufConfigurationCodeSearch(queryPrefix string) (CodeSearch, error) {
+	return makePrefixedCodeSearch(queryPrefix, query.Path("**/buf.yaml"))
 }
 
-func MakeBufConfigurationCodeSearch(queryPrefix string) CodeSearch {
-	return makePrefixedCodeSearch(queryPrefix, "path:**/buf.yaml")
+func MakeCargoConfigurationCodeSearch(queryPrefix string) (CodeSearch, error) {
+	return makePrefixedCodeSearch(queryPrefix, query.Path("**/Cargo.toml"))
 }
 
-func MakeCargoConfigurationCodeSearch(queryPrefix string) CodeSearch {
-	return makePrefixedCodeSearch(queryPrefix, "path:**/Cargo.toml")
+func MakeContainerConfigurationCodeSearch(queryPrefix string) (CodeSearch, error) {
+	return makePrefixedCodeSearch(queryPrefix, query.AndOf(
+		query.OrOf(query.Path("**/Containerfile"), query.Path("**/Dockerfile")),
+		query.Word("FROM"),
+	))
 }
 
-func MakeContainerConfigurationCodeSearch(queryPrefix string) CodeSearch {
-	return makePrefixedCodeSearch(queryPrefix, "path:**/Containerfile OR path:**/Dockerfile FROM")
+func MakeGoModuleCodeSearch(queryPrefix string) (CodeSearch, error) {
+	return makePrefixedCodeSearch(queryPrefix, query.Path("**/go.mod"))
 }
 
-func MakeGoModuleCodeSearch(queryPrefix string) CodeSearch {
-	return makePrefixedCodeSearch(queryPrefix, "path:**/go.mod")
+func MakePoetryConfigurationCodeSearch(queryPrefix string) (CodeSearch, error) {
+	return makePrefixedCodeSearch(queryPrefix, query.Path("**/poetry.lock"))
 }
 
-func MakePoetryConfigurationCodeSearch(queryPrefix string) CodeSearch {
-	return makePrefixedCodeSearch(queryPrefix, "path:**/poetry.lock")
+func MakeProtobufDefinitionCodeSearch(queryPrefix string) (CodeSearch, error) {
+	return makePrefixedCodeSearch(queryPrefix, query.Path("*.proto"))
 }
 
-func MakeProtobufDefinitionCodeSearch(queryPrefix string) CodeSearch {
-	return makePrefixedCodeSearch(queryPrefix, "path:*.proto")
+func MakeSkaffoldConfigurationCodeSearch(queryPrefix string) (CodeSearch, error) {
+	return makePrefixedCodeSearch(queryPrefix, query.Path("**/skaffold.yaml"))
 }
 
-func MakeSkaffoldConfigurationCodeSearch(queryPrefix string) CodeSearch {
-	return makePrefixedCodeSearch(queryPrefix, "path:**/skaffold.yaml")
+func MakeTerraformBackendCodeSearch(queryPrefix string) (CodeSearch, error) {
+	return makePrefixedCodeSearch(queryPrefix, query.AndOf(query.Path("*.tf"), query.Word("backend")))
 }
 
-func MakeTerraformBackendCodeSearch(queryPrefix string) CodeSearch {
-	return makePrefixedCodeSearch(queryPrefix, "path:*.tf backend")
+func MakeTerraformGKECluster(queryPrefix string) (CodeSearch, error) {
+	return makePrefixedCodeSearch(queryPrefix, query.AndOf(query.Path("*.tf"), query.Phrase("google_container_cluster")))
 }
 
-func MakeTerraformGKECluster(queryPrefix string) CodeSearch {
-	return makePrefixedCodeSearch(queryPrefix, `path:*.tf "google_container_cluster"`)
+// MakeQueryCodeSearch returns a MakeCodeSearchFunc for q, limited to
+// maxPageNumber pages. It fails if q does not parse.
+func MakeQueryCodeSearch(q string, maxPageNumber int) (MakeCodeSearchFunc, error) {
+	n, err := parseQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	return func(queryPrefix string) (CodeSearch, error) {
+		cs, err := makePrefixedCodeSearch(queryPrefix, n)
+		cs.MaxPageNumber = maxPageNumber
+		return cs, err
+	}, nil
 }
 
-func makePrefixedCodeSearch(queryPrefix, query string) CodeSearch {
+func makePrefixedCodeSearch(queryPrefix string, q query.Node) (CodeSearch, error) {
+	prefix, err := parseQuery(queryPrefix)
+	if err != nil {
+		return CodeSearch{}, fmt.Errorf("query_prefix %q: %w", queryPrefix, err)
+	}
 	return CodeSearch{
-		Query: fmt.Sprint(queryPrefix, query),
+		Query: query.AndOf(prefix, q).String(),
+	}, nil
+}
+
+// parseQuery parses q. Queries which do not parse are not passed on
+// verbatim, their operators would bind to the qualifiers they are
+// combined with. Empty queries yield nil.
+func parseQuery(q string) (query.Node, error) {
+	if strings.TrimSpace(q) == "" {
+		return nil, nil
 	}
+	return query.Parse(q)
 }
@@ -3,10 +3,15 @@ package job
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/abergmeier/knollledge/internal/github"
+	"github.com/abergmeier/knollledge/internal/query"
+	"github.com/abergmeier/knollledge/internal/search"
 	"github.com/google/go-cmp/cmp"
 )
 
@@ -29,16 +34,185 @@ func TestCodeSearchDecode(t *testing.T) {
 			Query:         "path:**.java class",
 			MaxPageNumber: 5,
 		},
+		{
+			MaxPageNumber: 2,
+			Preset:        "go-modules",
+			QueryPrefix:   "org:abergmeier ",
+		},
 	})
 	if diff != "" {
 		t.Fatalf("Decode result diff:\n%s\n", diff)
 	}
 }
 
-func TestMustRunCodeSearch(t *testing.T) {
-	c := github.NewTestClient()
+// pagedSearcher serves pages total pages. Each page carries a result of
+// its own, one shared by all pages and a language facet. Fetching page
+// failAt fails.
+func pagedSearcher(t *testing.T, pages uint, failAt uint) search.Searcher {
+	return search.SearcherFunc(func(ctx context.Context, query string, cursor search.Cursor) (*search.Page, error) {
+		pn := uint(cursor.Page)
+		if pn == 0 {
+			pn = 1
+		}
+		if pn > pages {
+			t.Fatal("Requested page beyond last page:", pn)
+		}
+		if pn == failAt {
+			return nil, errors.New("boom")
+		}
+		p := &search.Page{
+			Cursor:       cursor,
+			Number:       pn,
+			TotalPages:   pages,
+			TotalResults: uint64(pages + 1),
+			Results: []*search.Result{
+				{Path: fmt.Sprintf("page%d.java", pn), RepoName: "a/b", Sha: fmt.Sprint(pn)},
+				{Path: "Shared.java", RepoName: "a/b", Sha: "s"},
+			},
+			Facets: []*search.FacetGroup{
+				{Kind: search.FacetKindLanguages, Facets: []*search.Facet{{Name: "Java", Occurrences: 1}}},
+			},
+		}
+		if pn < pages {
+			p.Next = &search.Cursor{Page: int(pn + 1)}
+		}
+		return p, nil
+	})
+}
+
+func TestRunPagination(t *testing.T) {
+	tests := []struct {
+		maxPage int
+		pages   uint
+		failAt  uint
+		stop    StopReason
+		fetched []uint
+	}{
+		{maxPage: 3, pages: 5, stop: StopMaxPage, fetched: []uint{1, 2, 3}},
+		{maxPage: 5, pages: 2, stop: StopExhausted, fetched: []uint{1, 2}},
+		{maxPage: 0, pages: 2, stop: StopMaxPage, fetched: []uint{1}},
+		{maxPage: 5, pages: 5, failAt: 3, stop: StopError, fetched: []uint{1, 2}},
+	}
+
+	for _, test := range tests {
+		cs := CodeSearch{
+			Query:         "path:**.java class",
+			MaxPageNumber: test.maxPage,
+			Searcher:      pagedSearcher(t, test.pages, test.failAt),
+		}
+		res, err := cs.Run(context.TODO())
+		jerr := &Error{}
+		if (err != nil) != (test.stop == StopError) || (err != nil && !errors.As(err, &jerr)) {
+			t.Errorf("Unexpected error for %+v: %v", test, err)
+		}
+		if res.Stop != test.stop {
+			t.Errorf("Expected stop %s, got %s", test.stop, res.Stop)
+		}
+		if diff := cmp.Diff(res.Pages, test.fetched); diff != "" {
+			t.Errorf("Unexpected pages fetched:\n%s\n", diff)
+		}
+
+		paths := []string{}
+		for _, r := range res.Results {
+			paths = append(paths, r.Path)
+		}
+		expected := []string{"page1.java", "Shared.java"}
+		for _, pn := range test.fetched[1:] {
+			expected = append(expected, fmt.Sprintf("page%d.java", pn))
+		}
+		if diff := cmp.Diff(paths, expected); diff != "" {
+			t.Errorf("Results not merged:\n%s\n", diff)
+		}
+		if java := res.Facets[0].Facets[0]; java.Occurrences != uint64(len(test.fetched)) {
+			t.Errorf("Facets not aggregated over %d pages: %d", len(test.fetched), java.Occurrences)
+		}
+	}
+}
+
+func TestRunNegativeMaxPage(t *testing.T) {
+	cs := CodeSearch{
+		Query:         "path:**.java class",
+		MaxPageNumber: -1,
+		Searcher: search.SearcherFunc(func(ctx context.Context, query string, cursor search.Cursor) (*search.Page, error) {
+			t.Fatal("Unexpected search for negative max_page")
+			return nil, nil
+		}),
+	}
+	res, err := cs.Run(context.TODO())
+	jerr := &Error{}
+	if !errors.As(err, &jerr) || res != nil {
+		t.Errorf("Expected negative max_page to be rejected, got %v, %v", res, err)
+	}
+}
+
+func TestRunBudget(t *testing.T) {
+	paged := pagedSearcher(t, 5, 0)
 	cs := CodeSearch{
-		Query: "path:**.java class",
+		Query:         "path:**.java class",
+		MaxPageNumber: 5,
+		Searcher: search.SearcherFunc(func(ctx context.Context, query string, cursor search.Cursor) (*search.Page, error) {
+			if cursor.Page == 3 {
+				return nil, fmt.Errorf("GET search: %w", github.ErrBudgetExhausted)
+			}
+			return paged.Search(ctx, query, cursor)
+		}),
+	}
+	res, err := cs.Run(context.TODO())
+	if !errors.Is(err, github.ErrBudgetExhausted) {
+		t.Fatalf("Expected ErrBudgetExhausted, got %v", err)
+	}
+	if res.Stop != StopBudget {
+		t.Errorf("Expected stop %s, got %s", StopBudget, res.Stop)
+	}
+	if diff := cmp.Diff(res.Pages, []uint{1, 2}); diff != "" {
+		t.Errorf("Unexpected pages fetched:\n%s\n", diff)
+	}
+}
+
+func TestPresetQueries(t *testing.T) {
+	presets := map[string]MakeCodeSearchFunc{
+		"path:**/BUILD OR path:**/BUILD.bazel":               MakeBazelPackageCodeSearch,
+		"path:**/buf.yaml":                                   MakeBufConfigurationCodeSearch,
+		"path:**/Cargo.toml":                                 MakeCargoConfigurationCodeSearch,
+		"(path:**/Containerfile OR path:**/Dockerfile) FROM": MakeContainerConfigurationCodeSearch,
+		"path:**/go.mod":                                     MakeGoModuleCodeSearch,
+		"path:**/poetry.lock":                                MakePoetryConfigurationCodeSearch,
+		"path:*.proto":                                       MakeProtobufDefinitionCodeSearch,
+		"path:**/skaffold.yaml":                              MakeSkaffoldConfigurationCodeSearch,
+		"path:*.tf backend":                                  MakeTerraformBackendCodeSearch,
+		`path:*.tf "google_container_cluster"`:               MakeTerraformGKECluster,
+	}
+	for expected, f := range presets {
+		cs, err := f("")
+		if err != nil {
+			t.Errorf("Making %s failed: %s", expected, err)
+			continue
+		}
+		q := cs.Query
+		if q != expected {
+			t.Errorf("Unexpected query:\n%s\nexpected:\n%s", q, expected)
+		}
+		n, err := query.Parse(q)
+		if err != nil {
+			t.Errorf("Parsing %s failed: %s", q, err)
+			continue
+		}
+		if n.String() != q {
+			t.Errorf("Query %s does not round-trip: %s", q, n)
+		}
+	}
+
+	prefixed, err := MakeBazelPackageCodeSearch("org:acme")
+	if err != nil {
+		t.Fatal("Making prefixed query failed:", err)
+	}
+	if prefixed.Query != "org:acme (path:**/BUILD OR path:**/BUILD.bazel)" {
+		t.Error("Prefix not applied to all alternatives:", prefixed.Query)
+	}
+
+	// Passed on verbatim, the OR would bind to the path qualifier.
+	_, err = MakeBazelPackageCodeSearch("(org:acme OR org:initech")
+	if err == nil || !strings.Contains(err.Error(), "query_prefix") {
+		t.Error("Expected unbalanced prefix to fail, got", err)
 	}
-	cs.mustRunWithClient(context.TODO(), c)
 }
@@ -0,0 +1,71 @@
+package job
+
+import (
+	"sort"
+
+	"github.com/abergmeier/knollledge/internal/search"
+)
+
+// facetAggregate sums up the facets of every page fetched by a CodeSearch.
+// Occurrences of facets with the same kind and name are added, the score
+// of the best ranked page is kept.
+type facetAggregate struct {
+	kinds  []string
+	facets map[string]map[string]*search.Facet
+}
+
+func (fa *facetAggregate) add(groups []*search.FacetGroup) {
+	if fa.facets == nil {
+		fa.facets = make(map[string]map[string]*search.Facet)
+	}
+
+	for _, g := range groups {
+		byName, ok := fa.facets[g.Kind]
+		if !ok {
+			byName = make(map[string]*search.Facet)
+			fa.facets[g.Kind] = byName
+			fa.kinds = append(fa.kinds, g.Kind)
+		}
+
+		for _, f := range g.Facets {
+			agg, ok := byName[f.Name]
+			if !ok {
+				c := *f
+				byName[f.Name] = &c
+				continue
+			}
+			agg.Occurrences += f.Occurrences
+			if f.Score > agg.Score {
+				agg.Score = f.Score
+			}
+		}
+	}
+}
+
+// groups returns the aggregated facets. Groups keep the order in which
+// their kind was first seen, facets are ordered by descending occurrences.
+func (fa *facetAggregate) groups() []*search.FacetGroup {
+	if len(fa.kinds) == 0 {
+		return nil
+	}
+
+	groups := make([]*search.FacetGroup, 0, len(fa.kinds))
+	for _, k := range fa.kinds {
+		byName := fa.facets[k]
+		g := &search.FacetGroup{
+			Kind:   k,
+			Facets: make([]*search.Facet, 0, len(byName)),
+		}
+		for _, f := range byName {
+			g.Facets = append(g.Facets, f)
+		}
+		sort.Slice(g.Facets, func(i, j int) bool {
+			if g.Facets[i].Occurrences != g.Facets[j].Occurrences {
+				return g.Facets[i].Occurrences > g.Facets[j].Occurrences
+			}
+			return g.Facets[i].Name < g.Facets[j].Name
+		})
+		groups = append(groups, g)
+	}
+	return groups
+}
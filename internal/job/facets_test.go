@@ -0,0 +1,55 @@
+package job
+
+import (
+	"testing"
+
+	"github.com/abergmeier/knollledge/internal/search"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFacetAggregate(t *testing.T) {
+	fa := facetAggregate{}
+	fa.add([]*search.FacetGroup{
+		{
+			Kind: search.FacetKindLanguages,
+			Facets: []*search.Facet{
+				{Name: "Java", Query: "language:Java", Occurrences: 10, Score: 0.5},
+				{Name: "Kotlin", Query: "language:Kotlin", Occurrences: 2, Score: 0.1},
+			},
+		},
+	})
+	fa.add([]*search.FacetGroup{
+		{
+			Kind: search.FacetKindRepositories,
+			Facets: []*search.Facet{
+				{Name: "google/guava", Owner: "google", Query: "repo:google/guava", Occurrences: 1, Score: 0.2},
+			},
+		},
+		{
+			Kind: search.FacetKindLanguages,
+			Facets: []*search.Facet{
+				{Name: "Kotlin", Query: "language:Kotlin", Occurrences: 12, Score: 0.3},
+				{Name: "Java", Query: "language:Java", Occurrences: 1, Score: 0.4},
+			},
+		},
+	})
+
+	diff := cmp.Diff(fa.groups(), []*search.FacetGroup{
+		{
+			Kind: search.FacetKindLanguages,
+			Facets: []*search.Facet{
+				{Name: "Kotlin", Query: "language:Kotlin", Occurrences: 14, Score: 0.3},
+				{Name: "Java", Query: "language:Java", Occurrences: 11, Score: 0.5},
+			},
+		},
+		{
+			Kind: search.FacetKindRepositories,
+			Facets: []*search.Facet{
+				{Name: "google/guava", Owner: "google", Query: "repo:google/guava", Occurrences: 1, Score: 0.2},
+			},
+		},
+	})
+	if diff != "" {
+		t.Fatalf("Aggregate diff:\n%s\n", diff)
+	}
+}
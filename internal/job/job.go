@@ -3,18 +3,83 @@ package job
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
+
+	"github.com/abergmeier/knollledge/internal/search"
 )
 
+// Job is a unit of work of a knollledge run. Run returns the result even
+// if it fails, as long as there is a partial one.
 type Job interface {
-	Run(ctx context.Context)
+	Run(ctx context.Context) (*Result, error)
 }
 
-func MustRun(ctx context.Context, css *CodeSearch, w io.Writer) {
-	res := css.mustRun(ctx)
-	enc := json.NewEncoder(w)
-	err := enc.Encode(res)
-	if err != nil {
-		panic(err)
+// StopReason tells why a CodeSearch stopped fetching pages.
+type StopReason string
+
+const (
+	// StopExhausted means all pages of the query were fetched.
+	StopExhausted StopReason = "exhausted"
+	// StopMaxPage means MaxPageNumber was reached before the last page.
+	StopMaxPage StopReason = "max_page"
+	// StopError means fetching a page failed.
+	StopError StopReason = "error"
+	// StopBudget means the request budget of the run was used up before
+	// the last page. The next run resumes from the checkpoint.
+	StopBudget StopReason = "budget"
+)
+
+// Result is the output of a CodeSearch. Results holds the results of all
+// fetched pages, deduplicated by repository, path and sha, Facets their
+// aggregated facets.
+type Result struct {
+	Query string `json:"query"`
+	// Label names the scope entries the query covers, if any.
+	Label string `json:"label,omitempty"`
+	// Pages lists the numbers of the fetched pages in fetch order.
+	Pages        []uint     `json:"pages,omitempty"`
+	Stop         StopReason `json:"stop"`
+	Error        string     `json:"error,omitempty"`
+	TotalPages   uint       `json:"total_pages,omitempty"`
+	TotalResults uint64     `json:"total_results,omitempty"`
+
+	Results []*search.Result     `json:"results,omitempty"`
+	Facets  []*search.FacetGroup `json:"facets,omitempty"`
+}
+
+// MustRun runs j and writes its result to w, even if it is incomplete.
+// It panics if the run failed.
+func MustRun(ctx context.Context, j Job, w io.Writer) {
+	res, runErr := j.Run(ctx)
+	if res != nil {
+		err := Write(w, res)
+		if err != nil {
+			panic(err)
+		}
+	}
+	if runErr != nil {
+		panic(runErr)
 	}
 }
+
+// Write encodes res as JSON to w.
+func Write(w io.Writer, res *Result) error {
+	enc := json.NewEncoder(w)
+	return enc.Encode(res)
+}
+
+// Error reports the job and query a failure occurred in.
+type Error struct {
+	Job   string
+	Query string
+	Err   error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("job %s (query %q) failed: %v", e.Job, e.Query, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,100 @@
+package job
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const manifestVersion = 1
+
+// ManifestFile is the name of the manifest in an output directory.
+const ManifestFile = "manifest.json"
+
+// Manifest maps the fingerprints naming the files of an output directory
+// back to the jobs which produced them.
+type Manifest struct {
+	Version int                       `json:"version"`
+	Jobs    map[string]*ManifestEntry `json:"jobs"`
+}
+
+// ManifestEntry describes the job of a fingerprint and its last run. Label
+// is informational only, entries are keyed by the fingerprint alone.
+type ManifestEntry struct {
+	Query         string     `json:"query"`
+	Label         string     `json:"label,omitempty"`
+	MaxPageNumber int        `json:"max_page"`
+	Backend       string     `json:"backend,omitempty"`
+	Output        string     `json:"output"`
+	Stop          StopReason `json:"stop,omitempty"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// LoadManifest reads the manifest of dir. A missing manifest yields an
+// empty one.
+func LoadManifest(dir string) (*Manifest, error) {
+	m := &Manifest{
+		Version: manifestVersion,
+		Jobs:    map[string]*ManifestEntry{},
+	}
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, m)
+	if err != nil {
+		return nil, err
+	}
+	if m.Jobs == nil {
+		m.Jobs = map[string]*ManifestEntry{}
+	}
+	return m, nil
+}
+
+// Output returns the name of the output file of cs.
+func Output(cs *CodeSearch) string {
+	return cs.Fingerprint() + ".json"
+}
+
+// Add records a run of cs. res may be nil if the run failed without a
+// partial result.
+func (m *Manifest) Add(cs *CodeSearch, res *Result) {
+	e := &ManifestEntry{
+		Query:         cs.Query,
+		Label:         cs.Label,
+		MaxPageNumber: cs.MaxPageNumber,
+		Backend:       cs.backend(),
+		Output:        Output(cs),
+		UpdatedAt:     time.Now().UTC(),
+	}
+	if res != nil {
+		e.Stop = res.Stop
+	}
+	m.Jobs[cs.Fingerprint()] = e
+}
+
+// Fingerprints returns the fingerprints of all jobs, sorted.
+func (m *Manifest) Fingerprints() []string {
+	fps := make([]string, 0, len(m.Jobs))
+	for fp := range m.Jobs {
+		fps = append(fps, fp)
+	}
+	sort.Strings(fps)
+	return fps
+}
+
+// Save writes m to dir atomically.
+func (m *Manifest) Save(dir string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, ManifestFile), data)
+}
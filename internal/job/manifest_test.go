@@ -0,0 +1,78 @@
+package job
+
+import (
+	"testing"
+
+	"github.com/abergmeier/knollledge/internal/github"
+	"github.com/abergmeier/knollledge/internal/rest"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestFingerprint(t *testing.T) {
+	cs := CodeSearch{
+		Query:         "path:**.java class",
+		MaxPageNumber: 5,
+	}
+	fp := cs.Fingerprint()
+	if fp != (&CodeSearch{Query: cs.Query, MaxPageNumber: 5}).Fingerprint() {
+		t.Fatal("Fingerprint not stable")
+	}
+	if len(fp) != len("v1-")+64 || fp[:3] != "v1-" {
+		t.Error("Unexpected fingerprint format:", fp)
+	}
+
+	changed := map[string]CodeSearch{
+		"query":    {Query: "path:**.java interface", MaxPageNumber: 5},
+		"max_page": {Query: cs.Query, MaxPageNumber: 4},
+		"backend":  {Query: cs.Query, MaxPageNumber: 5, Searcher: rest.NewClient(nil).Searcher()},
+	}
+	for name, other := range changed {
+		if other.Fingerprint() == fp {
+			t.Errorf("Fingerprint ignores %s", name)
+		}
+	}
+
+	cs.Searcher = github.NewClient(nil).CodeSearch().Searcher()
+	if cs.Fingerprint() != fp {
+		t.Error("Explicit cs.github.com backend changes fingerprint")
+	}
+}
+
+func TestManifest(t *testing.T) {
+	dir := t.TempDir()
+	m, err := LoadManifest(dir)
+	if err != nil {
+		t.Fatal("Loading missing manifest failed:", err)
+	}
+
+	cs := &CodeSearch{
+		Query:         "path:**.java class",
+		MaxPageNumber: 5,
+	}
+	m.Add(cs, &Result{Stop: StopMaxPage})
+	err = m.Save(dir)
+	if err != nil {
+		t.Fatal("Save failed:", err)
+	}
+
+	loaded, err := LoadManifest(dir)
+	if err != nil {
+		t.Fatal("Load failed:", err)
+	}
+	diff := cmp.Diff(loaded, &Manifest{
+		Version: 1,
+		Jobs: map[string]*ManifestEntry{
+			cs.Fingerprint(): {
+				Query:         cs.Query,
+				MaxPageNumber: 5,
+				Backend:       "cs",
+				Output:        cs.Fingerprint() + ".json",
+				Stop:          StopMaxPage,
+			},
+		},
+	}, cmpopts.IgnoreFields(ManifestEntry{}, "UpdatedAt"))
+	if diff != "" {
+		t.Errorf("Unexpected manifest:\n%s\n", diff)
+	}
+}
@@ -0,0 +1,25 @@
+package job_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/abergmeier/knollledge/internal/github/githubtest"
+	"github.com/abergmeier/knollledge/internal/job"
+)
+
+// TestMustRunCodeSearch is not part of package job, as githubtest looks
+// up the credentials to record with through package config.
+func TestMustRunCodeSearch(t *testing.T) {
+	// The job sends the single request TestRequest of package github
+	// sends, so both replay the same cassette.
+	c := githubtest.NewCassetteClient(t, "../github/testdata/cassettes/request.json")
+	cs := job.CodeSearch{
+		Query:    "path:**.java class",
+		Searcher: c.CodeSearch().Searcher(),
+	}
+	_, err := cs.Run(context.TODO())
+	if err != nil {
+		t.Fatal("Run failed:", err)
+	}
+}
@@ -0,0 +1,186 @@
+package job
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/abergmeier/knollledge/internal/query"
+	"github.com/abergmeier/knollledge/internal/search"
+)
+
+// Limits bound a single query of a backend. Zero means unbounded.
+type Limits struct {
+	// Length is the number of characters of a query.
+	Length int
+	// Operators is the number of AND, OR and NOT operators of a query.
+	Operators int
+}
+
+// LimitsOf returns the limits of queries to backend. The REST API
+// rejects queries longer than 256 characters or with more than five
+// operators. cs.github.com documents no limits.
+func LimitsOf(backend string) Limits {
+	if backend == search.BackendREST {
+		return Limits{Length: 256, Operators: 5}
+	}
+	return Limits{}
+}
+
+// check returns an error if q exceeds l.
+func (l Limits) check(q query.Node) error {
+	if s := q.String(); l.Length != 0 && len(s) > l.Length {
+		return fmt.Errorf("query of %d characters exceeds the limit of %d", len(s), l.Length)
+	}
+	if n := query.Operators(q); l.Operators != 0 && n > l.Operators {
+		return fmt.Errorf("query with %d operators exceeds the limit of %d", n, l.Operators)
+	}
+	return nil
+}
+
+// Scope restricts a query to organizations, repositories or users.
+// Entries are combined with OR, Exclude removes repositories from the
+// organizations and users owning them.
+type Scope struct {
+	Orgs  []string `json:"orgs,omitempty"`
+	Repos []string `json:"repos,omitempty"`
+	Users []string `json:"users,omitempty"`
+	// Exclude lists repositories as owner/name.
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// entry is a qualifier of a scope and the owner of the repositories it
+// covers, or "" if it covers a single repository.
+type entry struct {
+	qualifier query.Node
+	owner     string
+}
+
+// entries returns an entry for every organization, repository and user
+// of s.
+func (s *Scope) entries() []entry {
+	es := make([]entry, 0, len(s.Orgs)+len(s.Repos)+len(s.Users))
+	for _, o := range s.Orgs {
+		es = append(es, entry{qualifier: query.Org(o), owner: o})
+	}
+	for _, r := range s.Repos {
+		es = append(es, entry{qualifier: query.Repo(r)})
+	}
+	for _, u := range s.Users {
+		es = append(es, entry{qualifier: query.User(u), owner: u})
+	}
+	return es
+}
+
+// exclusions returns the exclusions of the repositories in Exclude owned
+// by one of owners. Other exclusions would not change the results.
+func (s *Scope) exclusions(owners ...string) []query.Node {
+	qs := []query.Node{}
+	for _, r := range s.Exclude {
+		if ownedBy(r, owners) {
+			qs = append(qs, query.NotOf(query.Repo(r)))
+		}
+	}
+	return qs
+}
+
+// ownedBy reports whether the repository r, given as owner/name, belongs
+// to one of owners.
+func ownedBy(r string, owners []string) bool {
+	owner, _, _ := strings.Cut(r, "/")
+	for _, o := range owners {
+		if strings.EqualFold(owner, o) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Scope) validate() error {
+	for _, r := range append(append([]string{}, s.Repos...), s.Exclude...) {
+		owner, name, ok := strings.Cut(r, "/")
+		if !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+			return fmt.Errorf("repository %q is not owner/name", r)
+		}
+	}
+	for _, e := range append(append(append([]string{}, s.Orgs...), s.Repos...), s.Users...) {
+		if e == "" || strings.ContainsAny(e, " \t\n\"()") {
+			return fmt.Errorf("scope entry %q is not a valid name", e)
+		}
+	}
+	if len(s.entries()) == 0 {
+		return fmt.Errorf("scope has no orgs, repos or users")
+	}
+	owners := append(append([]string{}, s.Orgs...), s.Users...)
+	for _, r := range s.Exclude {
+		if !ownedBy(r, owners) {
+			return fmt.Errorf("excluded repository %q is in none of the orgs or users of the scope", r)
+		}
+	}
+	return nil
+}
+
+// Expand returns the jobs cs stands for. Jobs without a Scope stand for
+// themselves. Jobs with a Scope are combined into a single query
+// restricted to all scope entries, unless FanOut is set or the combined
+// query exceeds the Limits of the backend of cs. Then there is one job
+// per scope entry, excluding only the repositories of that entry. The
+// returned jobs carry the scope they cover as Label.
+func (cs *CodeSearch) Expand() ([]*CodeSearch, error) {
+	if cs.Scope == nil {
+		return []*CodeSearch{cs}, nil
+	}
+	if err := cs.Scope.validate(); err != nil {
+		return nil, cs.error(err)
+	}
+
+	limits := LimitsOf(cs.backend())
+	entries := cs.Scope.entries()
+
+	if !cs.FanOut {
+		qualifiers := make([]query.Node, len(entries))
+		owners := []string{}
+		for i, e := range entries {
+			qualifiers[i] = e.qualifier
+			if e.owner != "" {
+				owners = append(owners, e.owner)
+			}
+		}
+		j, q, err := cs.scoped(query.OrOf(qualifiers...), cs.Scope.exclusions(owners...))
+		if err != nil {
+			return nil, cs.error(err)
+		}
+		if limits.check(q) == nil {
+			return []*CodeSearch{j}, nil
+		}
+	}
+
+	jobs := make([]*CodeSearch, len(entries))
+	for i, e := range entries {
+		j, q, err := cs.scoped(e.qualifier, cs.Scope.exclusions(e.owner))
+		if err != nil {
+			return nil, cs.error(err)
+		}
+		if err := limits.check(q); err != nil {
+			return nil, j.error(fmt.Errorf("scope %s: %w", j.Label, err))
+		}
+		jobs[i] = j
+	}
+	return jobs, nil
+}
+
+// scoped returns a copy of cs restricted by qualifiers and exclusions
+// along with its query. It fails if the query of cs does not parse.
+func (cs *CodeSearch) scoped(qualifiers query.Node, exclusions []query.Node) (*CodeSearch, query.Node, error) {
+	n, err := parseQuery(cs.Query)
+	if err != nil {
+		return nil, nil, err
+	}
+	j := *cs
+	j.Scope = nil
+	j.FanOut = false
+	j.Label = qualifiers.String()
+	nodes := append([]query.Node{qualifiers}, exclusions...)
+	q := query.AndOf(append(nodes, n)...)
+	j.Query = q.String()
+	return &j, q, nil
+}
@@ -0,0 +1,226 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/abergmeier/knollledge/internal/rest"
+	"github.com/abergmeier/knollledge/internal/search"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestExpandCombined(t *testing.T) {
+	cs := &CodeSearch{
+		Query: "path:**/go.mod",
+		Scope: &Scope{
+			Orgs:    []string{"acme"},
+			Repos:   []string{"initech/tps"},
+			Exclude: []string{"acme/legacy"},
+		},
+	}
+	jobs, err := cs.Expand()
+	if err != nil {
+		t.Fatal("Expand failed:", err)
+	}
+	if len(jobs) != 1 {
+		t.Fatal("Unexpected number of jobs:", len(jobs))
+	}
+	j := jobs[0]
+	if j.Query != "(org:acme OR repo:initech/tps) NOT repo:acme/legacy path:**/go.mod" {
+		t.Error("Unexpected query:", j.Query)
+	}
+	if j.Label != "org:acme OR repo:initech/tps" || j.Scope != nil {
+		t.Errorf("Unexpected job: %+v", j)
+	}
+	if cs.Scope == nil {
+		t.Error("Expand modified the original job")
+	}
+}
+
+func TestExpandGroupsOr(t *testing.T) {
+	cs := &CodeSearch{
+		Query: "path:**/BUILD OR path:**/BUILD.bazel",
+		Scope: &Scope{Orgs: []string{"acme"}},
+	}
+	jobs, err := cs.Expand()
+	if err != nil {
+		t.Fatal("Expand failed:", err)
+	}
+	if q := jobs[0].Query; q != "org:acme (path:**/BUILD OR path:**/BUILD.bazel)" {
+		t.Error("Alternatives not grouped:", q)
+	}
+
+	for _, q := range []string{
+		"(path:**/BUILD OR path:**/BUILD.bazel)",
+		`"a OR b"`,
+		"ORDER",
+	} {
+		cs = &CodeSearch{Query: q, Scope: &Scope{Orgs: []string{"acme"}}}
+		jobs, err = cs.Expand()
+		if err != nil {
+			t.Fatal("Expand failed:", err)
+		}
+		if jobs[0].Query != "org:acme "+q {
+			t.Errorf("Query %s grouped needlessly: %s", q, jobs[0].Query)
+		}
+	}
+}
+
+func TestExpandFanOut(t *testing.T) {
+	cs := &CodeSearch{
+		Query:  "path:**/go.mod",
+		Scope:  &Scope{Orgs: []string{"acme", "initech"}, Users: []string{"abergmeier"}},
+		FanOut: true,
+	}
+	jobs, err := cs.Expand()
+	if err != nil {
+		t.Fatal("Expand failed:", err)
+	}
+
+	queries := []string{}
+	labels := []string{}
+	for _, j := range jobs {
+		queries = append(queries, j.Query)
+		labels = append(labels, j.Label)
+	}
+	if diff := cmp.Diff(queries, []string{
+		"org:acme path:**/go.mod",
+		"org:initech path:**/go.mod",
+		"user:abergmeier path:**/go.mod",
+	}); diff != "" {
+		t.Errorf("Unexpected queries:\n%s\n", diff)
+	}
+	if diff := cmp.Diff(labels, []string{"org:acme", "org:initech", "user:abergmeier"}); diff != "" {
+		t.Errorf("Unexpected labels:\n%s\n", diff)
+	}
+}
+
+func TestExpandFanOutExclusions(t *testing.T) {
+	cs := &CodeSearch{
+		Query:  "path:**/go.mod",
+		Scope:  &Scope{Orgs: []string{"acme", "initech"}, Repos: []string{"umbrella/hive"}, Exclude: []string{"acme/legacy", "Initech/tps"}},
+		FanOut: true,
+	}
+	jobs, err := cs.Expand()
+	if err != nil {
+		t.Fatal("Expand failed:", err)
+	}
+	queries := []string{}
+	for _, j := range jobs {
+		queries = append(queries, j.Query)
+	}
+	if diff := cmp.Diff(queries, []string{
+		"org:acme NOT repo:acme/legacy path:**/go.mod",
+		"org:initech NOT repo:Initech/tps path:**/go.mod",
+		"repo:umbrella/hive path:**/go.mod",
+	}); diff != "" {
+		t.Errorf("Unexpected queries:\n%s\n", diff)
+	}
+}
+
+func TestExpandTooBroad(t *testing.T) {
+	rest := rest.NewClient(nil).Searcher()
+	limits := LimitsOf(search.BackendOf(rest))
+
+	orgs := []string{}
+	for i := 0; i < limits.Operators+2; i++ {
+		orgs = append(orgs, fmt.Sprint("org", i))
+	}
+	cs := &CodeSearch{Query: "path:**/go.mod", Scope: &Scope{Orgs: orgs}, Searcher: rest}
+	jobs, err := cs.Expand()
+	if err != nil {
+		t.Fatal("Expand failed:", err)
+	}
+	if len(jobs) != len(orgs) {
+		t.Error("Too many operators not fanned out:", len(jobs))
+	}
+
+	// Operators of the query and exclusions count as well.
+	cs = &CodeSearch{
+		Query:    "path:**/BUILD OR path:**/BUILD.bazel",
+		Scope:    &Scope{Orgs: []string{"acme", "initech"}, Exclude: []string{"acme/a", "acme/b", "initech/c", "initech/d"}},
+		Searcher: rest,
+	}
+	jobs, err = cs.Expand()
+	if err != nil {
+		t.Fatal("Expand failed:", err)
+	}
+	if len(jobs) != 2 {
+		t.Error("Too many operators not fanned out:", len(jobs))
+	}
+
+	cs = &CodeSearch{Query: "path:**/go.mod", Scope: &Scope{Orgs: []string{strings.Repeat("a", 130), strings.Repeat("b", 130)}}, Searcher: rest}
+	jobs, err = cs.Expand()
+	if err != nil {
+		t.Fatal("Expand failed:", err)
+	}
+	if len(jobs) != 2 {
+		t.Error("Too long query not fanned out:", len(jobs))
+	}
+
+	// cs.github.com has no such limits.
+	cs = &CodeSearch{Query: "path:**/go.mod", Scope: &Scope{Orgs: orgs}}
+	jobs, err = cs.Expand()
+	if err != nil {
+		t.Fatal("Expand failed:", err)
+	}
+	if len(jobs) != 1 {
+		t.Error("Query for cs.github.com fanned out:", len(jobs))
+	}
+
+	// Fanning out does not help if a single job is too broad.
+	exclude := []string{}
+	for i := 0; i < limits.Operators+1; i++ {
+		exclude = append(exclude, fmt.Sprint("acme/repo", i))
+	}
+	cs = &CodeSearch{Query: "path:**/go.mod", Scope: &Scope{Orgs: []string{"acme"}, Exclude: exclude}, Searcher: rest}
+	_, err = cs.Expand()
+	if err == nil || !strings.Contains(err.Error(), "exceeds the limit") {
+		t.Error("Expected too broad job to fail, got", err)
+	}
+}
+
+func TestExpandInvalid(t *testing.T) {
+	invalid := []*Scope{
+		{},
+		{Repos: []string{"tps"}},
+		{Orgs: []string{"acme"}, Exclude: []string{"acme/legacy/x"}},
+		{Orgs: []string{"acme corp"}},
+		{Orgs: []string{"acme"}, Repos: []string{"initech/tps"}, Exclude: []string{"initech/legacy"}},
+	}
+	for _, s := range invalid {
+		cs := &CodeSearch{Query: "path:**/go.mod", Scope: s}
+		if _, err := cs.Expand(); err == nil {
+			t.Errorf("Expected scope %+v to fail", s)
+		}
+	}
+
+	// Passed on verbatim, the OR would bind to the scope qualifier.
+	cs := &CodeSearch{Query: "(path:**/BUILD OR path:**/BUILD.bazel", Scope: &Scope{Orgs: []string{"acme"}}}
+	if _, err := cs.Expand(); err == nil {
+		t.Error("Expected query which does not parse to fail")
+	}
+}
+
+func TestRunLabel(t *testing.T) {
+	cs := &CodeSearch{
+		Query:    "path:**.java class",
+		Scope:    &Scope{Orgs: []string{"acme", "initech"}},
+		FanOut:   true,
+		Searcher: pagedSearcher(t, 1, 0),
+	}
+	if _, err := cs.Run(context.TODO()); err == nil {
+		t.Error("Expected unexpanded job to fail")
+	}
+
+	jobs, _ := cs.Expand()
+	res, err := jobs[1].Run(context.TODO())
+	if err != nil {
+		t.Fatal("Run failed:", err)
+	}
+	if res.Label != "org:initech" {
+		t.Error("Unexpected label:", res.Label)
+	}
+}
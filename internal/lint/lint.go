@@ -0,0 +1,145 @@
+// Package lint checks job files before they are run, so broken queries
+// never spend rate limit.
+package lint
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/abergmeier/knollledge/internal/config"
+	"github.com/abergmeier/knollledge/internal/job"
+	"github.com/abergmeier/knollledge/internal/query"
+)
+
+// Problem is a problem of a job in a job file.
+type Problem struct {
+	File string
+	Line int
+	Msg  string
+}
+
+func (p *Problem) String() string {
+	return fmt.Sprintf("%s:%d: %s", p.File, p.Line, p.Msg)
+}
+
+// Linter checks job files. It remembers the jobs of all files it checked
+// to report duplicates across files.
+type Linter struct {
+	seen map[string]*Problem
+}
+
+func New() *Linter {
+	return &Linter{
+		seen: map[string]*Problem{},
+	}
+}
+
+// File checks the job file path.
+func (l *Linter) File(path string) ([]*Problem, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return l.Lint(path, data), nil
+}
+
+// Lint checks the jobs data holds in the job.CodeSearch JSON format. name
+// is the file name problems are reported with.
+func (l *Linter) Lint(name string, data []byte) []*Problem {
+	problems := []*Problem{}
+	report := func(offset int64, format string, a ...interface{}) {
+		problems = append(problems, &Problem{
+			File: name,
+			Line: lineOf(data, offset),
+			Msg:  fmt.Sprintf(format, a...),
+		})
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if t, err := dec.Token(); err != nil || t != json.Delim('[') {
+		report(0, "job file is not a JSON array of jobs")
+		return problems
+	}
+
+	for dec.More() {
+		offset := dec.InputOffset()
+		raw := json.RawMessage{}
+		err := dec.Decode(&raw)
+		if err != nil {
+			report(jsonOffset(err, offset), "invalid JSON: %s", err)
+			return problems
+		}
+		offset = skipSeparators(data, offset)
+
+		cs := &job.CodeSearch{}
+		strict := json.NewDecoder(bytes.NewReader(raw))
+		strict.DisallowUnknownFields()
+		err = strict.Decode(cs)
+		if err != nil {
+			report(offset, "invalid job: %s", err)
+			continue
+		}
+
+		err = config.Resolve(cs)
+		if err != nil {
+			report(offset, "%s", err)
+			continue
+		}
+		if cs.MaxPageNumber < 0 {
+			report(offset, "max_page is negative")
+		}
+		for _, i := range query.Lint(cs.Query) {
+			report(offset, "query %q: %s", cs.Query, i)
+		}
+
+		jobs, err := cs.Expand()
+		if err != nil {
+			report(offset, "%s", errors.Unwrap(err))
+			continue
+		}
+		for _, j := range jobs {
+			p := &Problem{File: name, Line: lineOf(data, offset)}
+			fp := j.Fingerprint()
+			if first, ok := l.seen[fp]; ok {
+				report(offset, "query %q duplicates the job at %s:%d", j.Query, first.File, first.Line)
+				continue
+			}
+			l.seen[fp] = p
+		}
+	}
+	return problems
+}
+
+// lineOf returns the 1-based line of offset in data.
+func lineOf(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return 1 + bytes.Count(data[:offset], []byte("\n"))
+}
+
+// skipSeparators returns the offset of the value following the
+// whitespace and comma at offset.
+func skipSeparators(data []byte, offset int64) int64 {
+	for offset < int64(len(data)) {
+		switch data[offset] {
+		case ' ', '\t', '\r', '\n', ',':
+			offset++
+		default:
+			return offset
+		}
+	}
+	return offset
+}
+
+// jsonOffset returns the offset of a JSON decoding error, or fallback.
+func jsonOffset(err error, fallback int64) int64 {
+	serr := &json.SyntaxError{}
+	if errors.As(err, &serr) {
+		return serr.Offset
+	}
+	return fallback
+}
@@ -0,0 +1,53 @@
+package lint
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func lintFiles(t *testing.T, l *Linter, files ...string) []string {
+	problems := []string{}
+	for _, f := range files {
+		ps, err := l.File(f)
+		if err != nil {
+			t.Fatal("Linting failed:", err)
+		}
+		for _, p := range ps {
+			problems = append(problems, p.String())
+		}
+	}
+	return problems
+}
+
+func TestLint(t *testing.T) {
+	problems := lintFiles(t, New(), "testdata/valid.json", "testdata/invalid.json")
+
+	diff := cmp.Diff(problems, []string{
+		`testdata/invalid.json:1: query "(path:**/go.mod OR path:**/go.sum": offset 0: unbalanced (`,
+		`testdata/invalid.json:5: query "path:**/Containerfile OR path:**/Dockerfile FROM": offset 22: ambiguous OR: implicit AND binds tighter, group the operands with parentheses`,
+		`testdata/invalid.json:7: query "pth:**/go.mod": offset 0: unknown qualifier pth:`,
+		`testdata/invalid.json:9: invalid job: json: unknown field "max_pages"`,
+		`testdata/invalid.json:12: unknown preset "no-such-preset"`,
+		`testdata/invalid.json:14: query "path:**.java class" duplicates the job at testdata/valid.json:1`,
+		`testdata/invalid.json:17: query "org:initech path:**/go.mod" duplicates the job at testdata/valid.json:4`,
+		`testdata/invalid.json:19: repository "tps" is not owner/name`,
+	})
+	if diff != "" {
+		t.Errorf("Unexpected problems:\n%s\n", diff)
+	}
+}
+
+func TestLintValid(t *testing.T) {
+	problems := lintFiles(t, New(), "testdata/valid.json")
+	if len(problems) != 0 {
+		t.Error("Unexpected problems:", problems)
+	}
+}
+
+func TestLintBrokenJSON(t *testing.T) {
+	problems := lintFiles(t, New(), "testdata/broken.json")
+	if len(problems) != 1 || problems[0][:len("testdata/broken.json:6:")] != "testdata/broken.json:6:" {
+		t.Error("Unexpected problems:", problems)
+	}
+}
@@ -0,0 +1,106 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Issue is a problem Lint found in a query.
+type Issue struct {
+	// Offset is the byte offset of the problem in the query.
+	Offset int
+	Msg    string
+}
+
+func (i *Issue) String() string {
+	return fmt.Sprintf("offset %d: %s", i.Offset, i.Msg)
+}
+
+// knownKeys are the qualifiers cs.github.com understands.
+var knownKeys = map[string]bool{
+	KeyPath:     true,
+	KeyRepo:     true,
+	KeyOrg:      true,
+	KeyUser:     true,
+	KeyLanguage: true,
+	KeySymbol:   true,
+	KeyContent:  true,
+	KeyIs:       true,
+}
+
+// Lint reports problems of q which would make a code search fail or
+// match other files than intended: syntax errors such as unbalanced
+// parentheses, unknown qualifiers, OR next to implicit AND without
+// parentheses and path globs which can never match.
+func Lint(q string) []*Issue {
+	p := &parser{
+		q: q,
+	}
+	err := p.scan()
+	if err != nil {
+		return []*Issue{syntaxIssue(err)}
+	}
+
+	issues := []*Issue{}
+	for _, t := range p.tokens {
+		if t.kind != tokenTerm {
+			continue
+		}
+		n := t.node
+		if not, ok := n.(Not); ok {
+			n = not.Node
+		}
+		qual, ok := n.(Qualifier)
+		if !ok {
+			continue
+		}
+		if !knownKeys[qual.Key] {
+			issues = append(issues, &Issue{Offset: t.offset, Msg: fmt.Sprintf("unknown qualifier %s:", qual.Key)})
+			continue
+		}
+		if text, ok := qual.Value.(Text); ok && qual.Key == KeyPath {
+			if reason := impossibleGlob(text.Value); reason != "" {
+				issues = append(issues, &Issue{Offset: t.offset, Msg: fmt.Sprintf("path glob %q can never match: %s", text.Value, reason)})
+			}
+		}
+	}
+
+	_, err = p.parse()
+	if err != nil {
+		return append(issues, syntaxIssue(err))
+	}
+	for _, offset := range p.ambiguous {
+		issues = append(issues, &Issue{Offset: offset, Msg: "ambiguous OR: implicit AND binds tighter, group the operands with parentheses"})
+	}
+	return issues
+}
+
+func syntaxIssue(err error) *Issue {
+	serr := err.(*SyntaxError)
+	return &Issue{Offset: serr.Offset, Msg: serr.Msg}
+}
+
+// impossibleGlob tells why no path in a repository matches glob, or
+// returns "" if some may.
+func impossibleGlob(glob string) string {
+	if strings.Contains(glob, `\`) {
+		return `paths are separated by /, not \`
+	}
+	for _, seg := range strings.Split(strings.TrimPrefix(glob, "/"), "/") {
+		switch {
+		case seg == "" && glob != "/" && !strings.HasSuffix(glob, "/"):
+			return "empty path segment"
+		case seg == "." || seg == "..":
+			return "paths in repositories are clean, without . or .. segments"
+		case strings.Contains(seg, "***"):
+			return "*** is not a glob operator, use * or **"
+		}
+	}
+	if strings.Count(glob, "[") != strings.Count(glob, "]") {
+		return "unbalanced character class"
+	}
+	if strings.Count(glob, "{") != strings.Count(glob, "}") {
+		return "unbalanced alternatives"
+	}
+	return ""
+}
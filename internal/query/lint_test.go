@@ -0,0 +1,58 @@
+package query
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLint(t *testing.T) {
+	tests := map[string][]*Issue{
+		"path:**/go.mod": {},
+		"(path:**/Containerfile OR path:**/Dockerfile) FROM": {},
+		"path:**/BUILD OR path:**/BUILD.bazel":               {},
+		"a AND b OR c":                                       {},
+		"path:**/Containerfile OR path:**/Dockerfile FROM": {
+			{Offset: 22, Msg: "ambiguous OR: implicit AND binds tighter, group the operands with parentheses"},
+		},
+		"(path:**/go.mod OR path:**/go.sum": {
+			{Offset: 0, Msg: "unbalanced ("},
+		},
+		"pth:**/go.mod -langauge:go": {
+			{Offset: 0, Msg: "unknown qualifier pth:"},
+			{Offset: 14, Msg: "unknown qualifier langauge:"},
+		},
+		`path:src\main.go path:a//b path:../x path:a/***/b path:[ab.go`: {
+			{Offset: 0, Msg: `path glob "src\\main.go" can never match: paths are separated by /, not \`},
+			{Offset: 17, Msg: `path glob "a//b" can never match: empty path segment`},
+			{Offset: 27, Msg: `path glob "../x" can never match: paths in repositories are clean, without . or .. segments`},
+			{Offset: 37, Msg: `path glob "a/***/b" can never match: *** is not a glob operator, use * or **`},
+			{Offset: 50, Msg: `path glob "[ab.go" can never match: unbalanced character class`},
+		},
+		`path:src/ "unterminated`: {
+			{Offset: 10, Msg: "unterminated quote"},
+		},
+	}
+	for q, expected := range tests {
+		if diff := cmp.Diff(Lint(q), expected); diff != "" {
+			t.Errorf("Unexpected issues of %q:\n%s\n", q, diff)
+		}
+	}
+}
+
+func TestLintPresets(t *testing.T) {
+	for _, n := range []Node{
+		OrOf(Path("**/BUILD"), Path("**/BUILD.bazel")),
+		AndOf(OrOf(Path("**/Containerfile"), Path("**/Dockerfile")), Word("FROM")),
+		AndOf(OrOf(AndOf(Org("acme"), Path("*.tf")), Repo("initech/tps")), Word("x")),
+	} {
+		if issues := Lint(n.String()); len(issues) != 0 {
+			s := []string{}
+			for _, i := range issues {
+				s = append(s, i.String())
+			}
+			t.Errorf("Rendered query %s has issues: %s", n, strings.Join(s, ", "))
+		}
+	}
+}
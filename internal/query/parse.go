@@ -0,0 +1,340 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// SyntaxError reports where a query fails to parse.
+type SyntaxError struct {
+	Query string
+	// Offset is the byte offset of the problem in Query.
+	Offset int
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("query %q: offset %d: %s", e.Query, e.Offset, e.Msg)
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenLParen
+	tokenRParen
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenTerm
+)
+
+type token struct {
+	kind   tokenKind
+	offset int
+	node   Node
+}
+
+// Parse parses a query in the cs.github.com syntax. Implicit AND binds
+// tighter than OR, so "a b OR c" parses as "(a b) OR c".
+func Parse(q string) (Node, error) {
+	p := &parser{
+		q: q,
+	}
+	err := p.scan()
+	if err != nil {
+		return nil, err
+	}
+	return p.parse()
+}
+
+// parse parses the scanned tokens.
+func (p *parser) parse() (Node, error) {
+	if p.peek().kind == tokenEOF {
+		return nil, p.errorf(0, "empty query")
+	}
+
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, p.errorf(t.offset, "unbalanced )")
+	}
+	return n, nil
+}
+
+type parser struct {
+	q      string
+	tokens []token
+	pos    int
+
+	// ambiguous holds the offsets of ORs with an operand combining
+	// several nodes by implicit AND without parentheses.
+	ambiguous []int
+}
+
+func (p *parser) errorf(offset int, format string, a ...interface{}) error {
+	return &SyntaxError{
+		Query:  p.q,
+		Offset: offset,
+		Msg:    fmt.Sprintf(format, a...),
+	}
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) parseOr() (Node, error) {
+	nodes := []Node{}
+	ors := []int{}
+	ambiguous := false
+	for {
+		n, implicit, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+		ambiguous = ambiguous || implicit
+		if p.peek().kind != tokenOr {
+			break
+		}
+		ors = append(ors, p.next().offset)
+	}
+	if ambiguous && len(ors) != 0 {
+		p.ambiguous = append(p.ambiguous, ors[0])
+	}
+	return OrOf(nodes...), nil
+}
+
+// parseAnd parses operands combined by AND. It also tells whether some
+// of them were combined without an AND operator.
+func (p *parser) parseAnd() (Node, bool, error) {
+	nodes := []Node{}
+	implicit := false
+	for {
+		n, err := p.parseUnary()
+		if err != nil {
+			return nil, false, err
+		}
+		nodes = append(nodes, n)
+
+		switch p.peek().kind {
+		case tokenAnd:
+			p.next()
+		case tokenOr, tokenRParen, tokenEOF:
+			return AndOf(nodes...), implicit, nil
+		default:
+			implicit = true
+		}
+	}
+}
+
+func (p *parser) parseUnary() (Node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenNot:
+		n, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return NotOf(n), nil
+	case tokenLParen:
+		if p.peek().kind == tokenRParen {
+			return nil, p.errorf(t.offset, "empty parentheses")
+		}
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next().kind != tokenRParen {
+			return nil, p.errorf(t.offset, "unbalanced (")
+		}
+		return n, nil
+	case tokenTerm:
+		return t.node, nil
+	case tokenRParen:
+		return nil, p.errorf(t.offset, "unbalanced )")
+	case tokenEOF:
+		return nil, p.errorf(t.offset, "missing operand")
+	default:
+		return nil, p.errorf(t.offset, "missing operand before %s", p.q[t.offset:t.offset+len(operatorOf(t.kind))])
+	}
+}
+
+func operatorOf(k tokenKind) string {
+	switch k {
+	case tokenAnd:
+		return "AND"
+	case tokenOr:
+		return "OR"
+	case tokenNot:
+		return "NOT"
+	}
+	return ""
+}
+
+// scan splits the query into tokens.
+func (p *parser) scan() error {
+	i := 0
+	for {
+		for i < len(p.q) && unicode.IsSpace(rune(p.q[i])) {
+			i++
+		}
+		if i == len(p.q) {
+			p.tokens = append(p.tokens, token{kind: tokenEOF, offset: i})
+			return nil
+		}
+
+		start := i
+		switch p.q[i] {
+		case '(':
+			p.tokens = append(p.tokens, token{kind: tokenLParen, offset: i})
+			i++
+			continue
+		case ')':
+			p.tokens = append(p.tokens, token{kind: tokenRParen, offset: i})
+			i++
+			continue
+		case '"':
+			t, end, err := p.scanQuoted(i)
+			if err != nil {
+				return err
+			}
+			p.tokens = append(p.tokens, token{kind: tokenTerm, offset: start, node: t})
+			i = end
+			continue
+		case '/':
+			r, end, err := p.scanRegex(i)
+			if err != nil {
+				return err
+			}
+			p.tokens = append(p.tokens, token{kind: tokenTerm, offset: start, node: r})
+			i = end
+			continue
+		}
+
+		negated := false
+		if p.q[i] == '-' && i+1 < len(p.q) && isQualifierStart(p.q[i+1:]) {
+			negated = true
+			i++
+		}
+
+		if key, ok := qualifierKey(p.q[i:]); ok {
+			i += len(key) + 1
+			var v Node
+			var err error
+			switch {
+			case i < len(p.q) && p.q[i] == '"':
+				v, i, err = p.scanQuoted(i)
+			case i < len(p.q) && p.q[i] == '/':
+				v, i, err = p.scanRegex(i)
+			default:
+				end := p.wordEnd(i)
+				if end == i {
+					return p.errorf(start, "qualifier %s: without value", key)
+				}
+				v, i = Text{Value: p.q[i:end]}, end
+			}
+			if err != nil {
+				return err
+			}
+			var n Node = Qualifier{Key: key, Value: v}
+			if negated {
+				n = NotOf(n)
+			}
+			p.tokens = append(p.tokens, token{kind: tokenTerm, offset: start, node: n})
+			continue
+		}
+
+		end := p.wordEnd(i)
+		w := p.q[i:end]
+		i = end
+		switch w {
+		case "AND":
+			p.tokens = append(p.tokens, token{kind: tokenAnd, offset: start})
+		case "OR":
+			p.tokens = append(p.tokens, token{kind: tokenOr, offset: start})
+		case "NOT":
+			p.tokens = append(p.tokens, token{kind: tokenNot, offset: start})
+		default:
+			p.tokens = append(p.tokens, token{kind: tokenTerm, offset: start, node: Text{Value: w}})
+		}
+	}
+}
+
+// wordEnd returns the end of the word starting at i.
+func (p *parser) wordEnd(i int) int {
+	for i < len(p.q) && !unicode.IsSpace(rune(p.q[i])) && p.q[i] != '(' && p.q[i] != ')' {
+		i++
+	}
+	return i
+}
+
+// scanQuoted scans the quoted string starting at i.
+func (p *parser) scanQuoted(i int) (Text, int, error) {
+	b := strings.Builder{}
+	for j := i + 1; j < len(p.q); j++ {
+		switch p.q[j] {
+		case '\\':
+			if j+1 < len(p.q) {
+				j++
+			}
+			b.WriteByte(p.q[j])
+		case '"':
+			return Text{Value: b.String(), Quoted: true}, j + 1, nil
+		default:
+			b.WriteByte(p.q[j])
+		}
+	}
+	return Text{}, 0, p.errorf(i, "unterminated quote")
+}
+
+// scanRegex scans the regular expression starting at i.
+func (p *parser) scanRegex(i int) (Regex, int, error) {
+	b := strings.Builder{}
+	for j := i + 1; j < len(p.q); j++ {
+		switch p.q[j] {
+		case '\\':
+			if j+1 < len(p.q) && p.q[j+1] == '/' {
+				j++
+			}
+			b.WriteByte(p.q[j])
+		case '/':
+			return Regex{Pattern: b.String()}, j + 1, nil
+		default:
+			b.WriteByte(p.q[j])
+		}
+	}
+	return Regex{}, 0, p.errorf(i, "unterminated regular expression")
+}
+
+// qualifierKey returns the key of the qualifier s starts with.
+func qualifierKey(s string) (string, bool) {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == ':':
+			return s[:i], i > 0
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_', i > 0 && c == '-':
+		default:
+			return "", false
+		}
+	}
+	return "", false
+}
+
+func isQualifierStart(s string) bool {
+	_, ok := qualifierKey(s)
+	return ok
+}
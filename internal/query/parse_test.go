@@ -0,0 +1,80 @@
+package query
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParse(t *testing.T) {
+	tests := map[string]Node{
+		"path:**/Containerfile OR path:**/Dockerfile FROM": OrOf(Path("**/Containerfile"), AndOf(Path("**/Dockerfile"), Word("FROM"))),
+		"(a OR b) AND c":            AndOf(OrOf(Word("a"), Word("b")), Word("c")),
+		"NOT a b":                   AndOf(NotOf(Word("a")), Word("b")),
+		"-repo:acme/legacy go":      AndOf(NotOf(Repo("acme/legacy")), Word("go")),
+		`path:"my dir/*.go" /a\/b/`: AndOf(Qualifier{Key: KeyPath, Value: Text{Value: "my dir/*.go", Quoted: true}}, RegexOf("a/b")),
+		`"say \"hi\"" a:b:c`:        AndOf(Phrase(`say "hi"`), Qualifier{Key: "a", Value: Text{Value: "b:c"}}),
+		"((a))":                     Word("a"),
+		"language:c++ foo-bar -baz": AndOf(Language("c++"), Word("foo-bar"), Word("-baz")),
+	}
+	for q, expected := range tests {
+		n, err := Parse(q)
+		if err != nil {
+			t.Errorf("Parsing %q failed: %s", q, err)
+			continue
+		}
+		if diff := cmp.Diff(n, expected); diff != "" {
+			t.Errorf("Unexpected tree of %q:\n%s\n", q, diff)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := map[string]int{
+		"":                0,
+		"(a OR b":         0,
+		"a OR b)":         6,
+		"a OR":            4,
+		"OR a":            0,
+		"a ()":            2,
+		`"unterminated`:   0,
+		"path:/unterm":    5,
+		"path: go":        0,
+		"a AND OR b":      6,
+		"language:go NOT": 15,
+	}
+	for q, offset := range tests {
+		_, err := Parse(q)
+		serr := &SyntaxError{}
+		if !errors.As(err, &serr) {
+			t.Errorf("Expected SyntaxError for %q, got %v", q, err)
+			continue
+		}
+		if serr.Offset != offset {
+			t.Errorf("Unexpected offset for %q: %d (%s)", q, serr.Offset, serr.Msg)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	nodes := []Node{
+		OrOf(Path("**/BUILD"), Path("**/BUILD.bazel")),
+		AndOf(OrOf(Path("**/Containerfile"), Path("**/Dockerfile")), Word("FROM")),
+		OrOf(AndOf(Org("acme"), Path("*.tf")), NotOf(Repo("initech/tps"))),
+		AndOf(Path("*.tf"), Phrase("google_container_cluster")),
+		AndOf(Content("hello world"), Language("Protocol Buffer"), Symbol("Run")),
+		AndOf(RegexOf(`func /\w+`), PathRegex(`^internal/`), Word("OR"), Word("a:b"), Word(`say "hi"`)),
+		NotOf(NotOf(Word("-x"))),
+	}
+	for _, n := range nodes {
+		parsed, err := Parse(n.String())
+		if err != nil {
+			t.Errorf("Parsing %s failed: %s", n, err)
+			continue
+		}
+		if diff := cmp.Diff(parsed.String(), n.String()); diff != "" {
+			t.Errorf("Rendering changed:\n%s\n", diff)
+		}
+	}
+}
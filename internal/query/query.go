@@ -0,0 +1,281 @@
+// Package query builds and parses cs.github.com code search queries.
+//
+// Queries are trees of Nodes. Rendering a Node groups operands explicitly,
+// so the precedence of the rendered query never depends on the reader
+// knowing that implicit AND binds tighter than OR:
+//
+//	query.And(query.Or(query.Path("**/Containerfile"), query.Path("**/Dockerfile")), query.Word("FROM"))
+//
+// renders as
+//
+//	(path:**/Containerfile OR path:**/Dockerfile) FROM
+package query
+
+import (
+	"strings"
+)
+
+// Node is a code search query or a part of it. String renders it in the
+// cs.github.com syntax.
+type Node interface {
+	String() string
+	node()
+}
+
+// Text matches content. Simple words render as is, everything else
+// quoted.
+type Text struct {
+	Value string
+	// Quoted forces quoting even if Value is a simple word.
+	Quoted bool
+}
+
+// Regex matches content or qualifier values by regular expression.
+type Regex struct {
+	Pattern string
+}
+
+// Qualifier restricts a query, e.g. path:**/go.mod or repo:owner/name.
+// Value is a Text or a Regex.
+type Qualifier struct {
+	Key   string
+	Value Node
+}
+
+// And matches what all of Nodes match.
+type And struct {
+	Nodes []Node
+}
+
+// Or matches what any of Nodes matches.
+type Or struct {
+	Nodes []Node
+}
+
+// Not matches what Node does not match.
+type Not struct {
+	Node Node
+}
+
+// Raw is query text the builder does not model. It renders verbatim, so
+// the caller is responsible for its grouping.
+type Raw string
+
+func (Text) node()      {}
+func (Regex) node()     {}
+func (Qualifier) node() {}
+func (And) node()       {}
+func (Or) node()        {}
+func (Not) node()       {}
+func (Raw) node()       {}
+
+// Keys of the qualifiers cs.github.com understands.
+const (
+	KeyPath     = "path"
+	KeyRepo     = "repo"
+	KeyOrg      = "org"
+	KeyUser     = "user"
+	KeyLanguage = "language"
+	KeySymbol   = "symbol"
+	KeyContent  = "content"
+	KeyIs       = "is"
+)
+
+// Path restricts matches to files whose path matches glob.
+func Path(glob string) Node {
+	return Qualifier{Key: KeyPath, Value: Text{Value: glob}}
+}
+
+// PathRegex restricts matches to files whose path matches pattern.
+func PathRegex(pattern string) Node {
+	return Qualifier{Key: KeyPath, Value: Regex{Pattern: pattern}}
+}
+
+// Repo restricts matches to the repository owner/name.
+func Repo(name string) Node {
+	return Qualifier{Key: KeyRepo, Value: Text{Value: name}}
+}
+
+// Org restricts matches to repositories of the organization name.
+func Org(name string) Node {
+	return Qualifier{Key: KeyOrg, Value: Text{Value: name}}
+}
+
+// User restricts matches to repositories of the user name.
+func User(name string) Node {
+	return Qualifier{Key: KeyUser, Value: Text{Value: name}}
+}
+
+// Language restricts matches to files of language.
+func Language(language string) Node {
+	return Qualifier{Key: KeyLanguage, Value: Text{Value: language}}
+}
+
+// Symbol matches definitions of the symbol name.
+func Symbol(name string) Node {
+	return Qualifier{Key: KeySymbol, Value: Text{Value: name}}
+}
+
+// Content matches text in file contents only, not in paths.
+func Content(text string) Node {
+	return Qualifier{Key: KeyContent, Value: Text{Value: text}}
+}
+
+// Word matches text.
+func Word(text string) Node {
+	return Text{Value: text}
+}
+
+// Phrase matches text exactly, rendering it quoted.
+func Phrase(text string) Node {
+	return Text{Value: text, Quoted: true}
+}
+
+// RegexOf matches content by the regular expression pattern.
+func RegexOf(pattern string) Node {
+	return Regex{Pattern: pattern}
+}
+
+// AndOf combines nodes with AND. Nested Ands are flattened and a single
+// node is returned as is.
+func AndOf(nodes ...Node) Node {
+	flat := []Node{}
+	for _, n := range nodes {
+		if a, ok := n.(And); ok {
+			flat = append(flat, a.Nodes...)
+		} else if n != nil {
+			flat = append(flat, n)
+		}
+	}
+	if len(flat) == 1 {
+		return flat[0]
+	}
+	return And{Nodes: flat}
+}
+
+// OrOf combines nodes with OR. Nested Ors are flattened and a single node
+// is returned as is.
+func OrOf(nodes ...Node) Node {
+	flat := []Node{}
+	for _, n := range nodes {
+		if o, ok := n.(Or); ok {
+			flat = append(flat, o.Nodes...)
+		} else if n != nil {
+			flat = append(flat, n)
+		}
+	}
+	if len(flat) == 1 {
+		return flat[0]
+	}
+	return Or{Nodes: flat}
+}
+
+// NotOf negates n.
+func NotOf(n Node) Node {
+	return Not{Node: n}
+}
+
+// Operators returns the number of AND, OR and NOT operators n renders
+// to. Implicit ANDs are not counted.
+func Operators(n Node) int {
+	switch n := n.(type) {
+	case And:
+		c := 0
+		for _, m := range n.Nodes {
+			c += Operators(m)
+		}
+		return c
+	case Or:
+		c := len(n.Nodes) - 1
+		for _, m := range n.Nodes {
+			c += Operators(m)
+		}
+		return c
+	case Not:
+		return 1 + Operators(n.Node)
+	}
+	return 0
+}
+
+func (t Text) String() string {
+	if t.Quoted || !isWord(t.Value) || isOperator(t.Value) || strings.ContainsAny(t.Value, ":") || strings.HasPrefix(t.Value, "-") {
+		return quote(t.Value)
+	}
+	return t.Value
+}
+
+// valueString renders t as the value of a qualifier, which may contain
+// colons and dashes.
+func (t Text) valueString() string {
+	if t.Quoted || !isWord(t.Value) {
+		return quote(t.Value)
+	}
+	return t.Value
+}
+
+func (r Regex) String() string {
+	return "/" + strings.ReplaceAll(r.Pattern, "/", `\/`) + "/"
+}
+
+func (q Qualifier) String() string {
+	v := ""
+	switch val := q.Value.(type) {
+	case Text:
+		v = val.valueString()
+	case nil:
+	default:
+		v = val.String()
+	}
+	return q.Key + ":" + v
+}
+
+func (a And) String() string {
+	parts := make([]string, len(a.Nodes))
+	for i, n := range a.Nodes {
+		parts[i] = group(n)
+	}
+	return strings.Join(parts, " ")
+}
+
+func (o Or) String() string {
+	parts := make([]string, len(o.Nodes))
+	for i, n := range o.Nodes {
+		parts[i] = group(n)
+	}
+	return strings.Join(parts, " OR ")
+}
+
+func (n Not) String() string {
+	return "NOT " + group(n.Node)
+}
+
+func (r Raw) String() string {
+	return string(r)
+}
+
+// group renders n, parenthesized if it combines several nodes.
+func group(n Node) string {
+	switch n.(type) {
+	case And, Or:
+		return "(" + n.String() + ")"
+	}
+	return n.String()
+}
+
+// isWord tells whether s can be rendered without quotes.
+func isWord(s string) bool {
+	if s == "" || strings.HasPrefix(s, "/") {
+		return false
+	}
+	return !strings.ContainsAny(s, " \t\r\n\"()\\")
+}
+
+func isOperator(s string) bool {
+	return s == "AND" || s == "OR" || s == "NOT"
+}
+
+func quote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
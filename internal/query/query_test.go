@@ -0,0 +1,41 @@
+package query
+
+import (
+	"testing"
+)
+
+func TestString(t *testing.T) {
+	tests := map[string]Node{
+		"path:**/BUILD OR path:**/BUILD.bazel":                     OrOf(Path("**/BUILD"), Path("**/BUILD.bazel")),
+		"(path:**/Containerfile OR path:**/Dockerfile) FROM":       AndOf(OrOf(Path("**/Containerfile"), Path("**/Dockerfile")), Word("FROM")),
+		"(org:acme path:*.tf) OR repo:initech/tps":                 OrOf(AndOf(Org("acme"), Path("*.tf")), Repo("initech/tps")),
+		`path:*.tf "google_container_cluster"`:                     AndOf(Path("*.tf"), Phrase("google_container_cluster")),
+		`content:"hello world" language:go`:                        AndOf(Content("hello world"), Language("go")),
+		`NOT (user:abergmeier OR org:acme)`:                        NotOf(OrOf(User("abergmeier"), Org("acme"))),
+		`symbol:Run /func \/\w+/ path:/^internal\//`:               AndOf(Symbol("Run"), RegexOf(`func /\w+`), PathRegex(`^internal/`)),
+		`"OR" "a:b" "say \"hi\""`:                                  AndOf(Word("OR"), Word("a:b"), Word(`say "hi"`)),
+		`org:acme repo:acme/knollledge language:"Protocol Buffer"`: AndOf(AndOf(Org("acme"), Repo("acme/knollledge")), Language("Protocol Buffer")),
+	}
+	for expected, n := range tests {
+		if s := n.String(); s != expected {
+			t.Errorf("Unexpected rendering:\n%s\nexpected:\n%s", s, expected)
+		}
+	}
+}
+
+func TestOperators(t *testing.T) {
+	tests := []struct {
+		n        Node
+		expected int
+	}{
+		{AndOf(Org("acme"), Path("*.tf"), Word("OR")), 0},
+		{OrOf(Path("**/BUILD"), Path("**/BUILD.bazel")), 1},
+		{AndOf(OrOf(Org("acme"), Repo("initech/tps"), User("abergmeier")), NotOf(Repo("acme/legacy"))), 3},
+		{NotOf(OrOf(AndOf(Org("acme"), NotOf(Path("*.tf"))), Repo("initech/tps"))), 3},
+	}
+	for _, test := range tests {
+		if c := Operators(test.n); c != test.expected {
+			t.Errorf("Unexpected number of operators in %s: %d, expected %d", test.n, c, test.expected)
+		}
+	}
+}
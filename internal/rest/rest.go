@@ -0,0 +1,180 @@
+// Package rest searches code with the official REST API of GitHub
+// (/search/code). Unlike cs.github.com it accepts personal access tokens
+// and GitHub App installation tokens.
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/abergmeier/knollledge/internal/github"
+	"github.com/abergmeier/knollledge/internal/search"
+	gh "github.com/google/go-github/v52/github"
+)
+
+const (
+	// maxResults is the number of results /search/code returns at most
+	// for a query, regardless of pagination.
+	maxResults = 1000
+
+	defaultPerPage = 100
+)
+
+// Client maps code search of the REST API onto the result types of
+// cs.github.com, so jobs can run against either.
+type Client struct {
+	client *gh.Client
+
+	// PerPage is the number of results per page, at most 100.
+	PerPage int
+}
+
+// NewClient returns a REST client using httpClient, which should
+// authenticate requests, e.g. through a github.AuthTransport.
+func NewClient(httpClient *http.Client) *Client {
+	return New(gh.NewClient(httpClient))
+}
+
+// New wraps an existing go-github client, e.g. one configured for GitHub
+// Enterprise.
+func New(client *gh.Client) *Client {
+	return &Client{
+		client:  client,
+		PerPage: defaultPerPage,
+	}
+}
+
+// Searcher returns a search.Searcher for the REST API.
+func (c *Client) Searcher() search.Searcher {
+	return github.NewSearcher(c, search.BackendREST)
+}
+
+// Search runs query and returns the page requested by opts. Only Page of
+// opts is used, the REST API has no page tokens.
+func (c *Client) Search(ctx context.Context, query string, opts *github.SearchOptions) (*github.CodeSearchResult, *github.Response, error) {
+	page := 1
+	if opts != nil && opts.Page > 0 {
+		page = opts.Page
+	}
+	perPage := c.PerPage
+	if perPage <= 0 {
+		perPage = defaultPerPage
+	}
+
+	csr, resp, err := c.client.Search.Code(ctx, query, &gh.SearchOptions{
+		TextMatch: true,
+		ListOptions: gh.ListOptions{
+			Page:    page,
+			PerPage: perPage,
+		},
+	})
+	response := toResponse(resp)
+	if err != nil {
+		return nil, response, toError(query, err)
+	}
+
+	total := csr.GetTotal()
+	reachable := total
+	if reachable > maxResults {
+		reachable = maxResults
+	}
+
+	result := &github.CodeSearchResult{
+		ResultsCount: uint64(total),
+		PageNumber:   uint(page),
+		TotalPages:   uint((reachable + perPage - 1) / perPage),
+		Results:      make([]*github.CodeResult, 0, len(csr.CodeResults)),
+	}
+	if response != nil {
+		result.RequestId = response.Header.Get("X-GitHub-Request-Id")
+	}
+	for _, cr := range csr.CodeResults {
+		result.Results = append(result.Results, toCodeResult(cr))
+	}
+	return result, response, nil
+}
+
+func toResponse(resp *gh.Response) *github.Response {
+	if resp == nil {
+		return nil
+	}
+	return &github.Response{
+		Response: resp.Response,
+		Rate: github.Rate{
+			Limit:     resp.Rate.Limit,
+			Remaining: resp.Rate.Remaining,
+			Reset:     github.Timestamp{Time: resp.Rate.Reset.Time},
+		},
+	}
+}
+
+// toError turns validation failures, which the REST API reports for
+// malformed queries, into a github.QueryError.
+func toError(query string, err error) error {
+	var eresp *gh.ErrorResponse
+	if !errors.As(err, &eresp) || eresp.Response == nil || eresp.Response.StatusCode != http.StatusUnprocessableEntity {
+		return err
+	}
+
+	qerr := &github.QueryError{
+		Query:   query,
+		Message: eresp.Message,
+	}
+	if eresp.Response.Header != nil {
+		qerr.RequestId = eresp.Response.Header.Get("X-GitHub-Request-Id")
+	}
+	for _, e := range eresp.Errors {
+		msg := e.Message
+		if msg == "" {
+			msg = fmt.Sprintf("%s %s", e.Field, e.Code)
+		}
+		qerr.Details = append(qerr.Details, &github.QueryErrorDetail{
+			Message:  msg,
+			Position: -1,
+		})
+	}
+	return qerr
+}
+
+// toCodeResult maps a REST result. The REST API reports neither the file
+// language nor the ref, and its match indices are relative to the
+// snippet, so Language, RefName and Matches stay empty. Snippets carry
+// the plain fragments without a start line.
+func toCodeResult(cr *gh.CodeResult) *github.CodeResult {
+	r := &github.CodeResult{
+		Path:      cr.GetPath(),
+		Sha:       cr.GetSHA(),
+		RepoId:    uint64(cr.GetRepository().GetID()),
+		RepoName:  cr.GetRepository().GetFullName(),
+		CommitSha: commitFromHTMLURL(cr.GetHTMLURL()),
+	}
+
+	for _, tm := range cr.TextMatches {
+		if tm.GetObjectType() != "FileContent" {
+			continue
+		}
+		r.Snippets = append(r.Snippets, &github.Snippet{
+			Lines: strings.Split(tm.GetFragment(), "\n"),
+		})
+		r.MatchCount += uint64(len(tm.Matches))
+	}
+	return r
+}
+
+// commitFromHTMLURL extracts the commit from URLs of the form
+// https://github.com/{owner}/{repo}/blob/{commit}/{path}.
+func commitFromHTMLURL(htmlURL string) string {
+	u, err := url.Parse(htmlURL)
+	if err != nil {
+		return ""
+	}
+	parts := strings.SplitN(strings.TrimPrefix(u.Path, "/"), "/", 5)
+	if len(parts) < 4 || parts[2] != "blob" {
+		return ""
+	}
+	return parts[3]
+}
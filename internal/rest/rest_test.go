@@ -0,0 +1,127 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/abergmeier/knollledge/internal/github"
+	"github.com/google/go-cmp/cmp"
+	gh "github.com/google/go-github/v52/github"
+)
+
+const codeSearchResponse = `{
+	"total_count": 2500,
+	"incomplete_results": false,
+	"items": [
+		{
+			"name": "go.mod",
+			"path": "go.mod",
+			"sha": "d5a1c5e0e1d8f2b4a3c6e7f8091a2b3c4d5e6f70",
+			"html_url": "https://github.com/abergmeier/knollledge/blob/8a7e3c1b2d4f5e6a7b8c9d0e1f2a3b4c5d6e7f80/go.mod",
+			"repository": {
+				"id": 629271234,
+				"full_name": "abergmeier/knollledge"
+			},
+			"text_matches": [
+				{
+					"object_type": "FileContent",
+					"property": "content",
+					"fragment": "module github.com/abergmeier/knollledge\n\ngo 1.18",
+					"matches": [
+						{"text": "module", "indices": [0, 6]}
+					]
+				}
+			]
+		}
+	]
+}`
+
+func newTestClient(t *testing.T, h http.HandlerFunc) *Client {
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+
+	ghc := gh.NewClient(&http.Client{
+		Transport: &github.AuthTransport{
+			Authenticator: &github.TokenAuthenticator{Token: "ghp_test"},
+		},
+	})
+	ghc.BaseURL, _ = url.Parse(srv.URL + "/")
+	return New(ghc)
+}
+
+func TestSearch(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/search/code" {
+			t.Error("Unexpected path:", r.URL.Path)
+		}
+		q := r.URL.Query()
+		if q.Get("q") != "path:**/go.mod" || q.Get("page") != "2" || q.Get("per_page") != "100" {
+			t.Error("Unexpected query:", r.URL.RawQuery)
+		}
+		if h := r.Header.Get("Authorization"); h != "Bearer ghp_test" {
+			t.Error("Unexpected Authorization header:", h)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-GitHub-Request-Id", "REST:1")
+		w.Header().Set("X-RateLimit-Limit", "30")
+		w.Header().Set("X-RateLimit-Remaining", "29")
+		w.Write([]byte(codeSearchResponse))
+	})
+
+	result, resp, err := c.Search(context.TODO(), "path:**/go.mod", &github.SearchOptions{
+		ListOptions: github.ListOptions{Page: 2},
+	})
+	if err != nil {
+		t.Fatal("Search failed:", err)
+	}
+	if resp.Rate.Limit != 30 || resp.Rate.Remaining != 29 {
+		t.Error("Unexpected Rate:", resp.Rate)
+	}
+
+	diff := cmp.Diff(result, &github.CodeSearchResult{
+		RequestId:    "REST:1",
+		ResultsCount: 2500,
+		PageNumber:   2,
+		TotalPages:   10,
+		Results: []*github.CodeResult{
+			{
+				Path:      "go.mod",
+				Sha:       "d5a1c5e0e1d8f2b4a3c6e7f8091a2b3c4d5e6f70",
+				RepoId:    629271234,
+				CommitSha: "8a7e3c1b2d4f5e6a7b8c9d0e1f2a3b4c5d6e7f80",
+				RepoName:  "abergmeier/knollledge",
+				Snippets: []*github.Snippet{
+					{
+						Lines: []string{"module github.com/abergmeier/knollledge", "", "go 1.18"},
+					},
+				},
+				MatchCount: 1,
+			},
+		},
+	})
+	if diff != "" {
+		t.Fatalf("Unexpected result:\n%s\n", diff)
+	}
+}
+
+func TestSearchValidationFailed(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write([]byte(`{"message": "Validation Failed", "errors": [{"resource": "Search", "field": "q", "code": "invalid", "message": "The search contains only logical operators"}]}`))
+	})
+
+	_, _, err := c.Search(context.TODO(), "OR", nil)
+	qerr := &github.QueryError{}
+	if !errors.As(err, &qerr) {
+		t.Fatalf("Expected QueryError, got %T: %v", err, err)
+	}
+	expected := `code search query "OR": Validation Failed; The search contains only logical operators`
+	if qerr.Error() != expected {
+		t.Errorf("Unexpected message:\n%s\nexpected:\n%s", qerr.Error(), expected)
+	}
+}
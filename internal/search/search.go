@@ -0,0 +1,120 @@
+// Package search defines the backend-neutral Searcher interface jobs run
+// their queries through. Backends provide their own adapters, see
+// github.CodeSearchService.Searcher and rest.Client.Searcher.
+package search
+
+import (
+	"context"
+)
+
+// Cursor identifies a page of the results of a query. The zero Cursor
+// refers to the first page. Backends which paginate by token may require
+// Token, others only look at Page.
+type Cursor struct {
+	Page  int    `json:"page,omitempty"`
+	Token string `json:"token,omitempty"`
+}
+
+// Page is a single page of the results of a query.
+type Page struct {
+	// Cursor is the cursor the page was fetched with.
+	Cursor Cursor
+	// Next is the cursor of the following page, nil if this is the last.
+	Next *Cursor
+
+	// Number is the 1-based number of the page.
+	Number       uint
+	TotalPages   uint
+	TotalResults uint64
+
+	Results []*Result
+	Facets  []*FacetGroup
+}
+
+// Result is a file matching a query. The types of results mirror the JSON
+// of cs.github.com, other backends fill in what they know.
+type Result struct {
+	Path        string       `json:"path,omitempty"`
+	Sha         string       `json:"sha,omitempty"`
+	RefName     string       `json:"ref_name,omitempty"`
+	Language    string       `json:"language,omitempty"`
+	RepoId      uint64       `json:"repo_id,omitempty"`
+	CommitSha   string       `json:"commit_sha,omitempty"`
+	RepoName    string       `json:"repo_name,omitempty"`
+	Snippets    []*Snippet   `json:"snippets,omitempty"`
+	MatchCount  uint64       `json:"match_count,omitempty"`
+	Matches     []*Match     `json:"matches,omitempty"`
+	ScoringInfo *ScoringInfo `json:"scoring_info,omitempty"`
+}
+
+// Snippet is an excerpt of a matched file. Lines of cs.github.com contain
+// highlighting markup (<span>, <mark>) and StartLine is the 1-based line
+// number of the first entry in Lines.
+type Snippet struct {
+	Lines     []string `json:"lines,omitempty"`
+	StartLine uint64   `json:"start_line,omitempty"`
+}
+
+// Match is the byte range [Start, End) of a single match within the file.
+type Match struct {
+	Start uint64 `json:"start"`
+	End   uint64 `json:"end"`
+}
+
+// ScoringInfo describes how the ranking score of a result came about.
+// Contributions[i] is what the factor with id Factors[i] added to Score.
+type ScoringInfo struct {
+	Score         float64   `json:"score"`
+	Factors       []int     `json:"factors,omitempty"`
+	Contributions []float64 `json:"contributions,omitempty"`
+	SymbolMatches uint64    `json:"symbol_matches"`
+}
+
+const (
+	FacetKindLanguages    = "Languages"
+	FacetKindRepositories = "Repositories"
+)
+
+// FacetGroup is a breakdown of the results of a query by one dimension,
+// such as language or repository. Kind is one of the FacetKind constants.
+type FacetGroup struct {
+	Kind   string   `json:"kind"`
+	Facets []*Facet `json:"facets,omitempty"`
+}
+
+// Facet is a single bucket of a FacetGroup. Query is the qualifier that
+// narrows a search down to this bucket (e.g. "language:Go").
+type Facet struct {
+	Name        string  `json:"name"`
+	Owner       string  `json:"owner,omitempty"`
+	Query       string  `json:"query,omitempty"`
+	Occurrences uint64  `json:"occurrences"`
+	Score       float64 `json:"score"`
+}
+
+// Searcher runs queries against a code search backend.
+type Searcher interface {
+	Search(ctx context.Context, query string, cursor Cursor) (*Page, error)
+}
+
+// SearcherFunc adapts a function to a Searcher.
+type SearcherFunc func(ctx context.Context, query string, cursor Cursor) (*Page, error)
+
+func (f SearcherFunc) Search(ctx context.Context, query string, cursor Cursor) (*Page, error) {
+	return f(ctx, query, cursor)
+}
+
+// Names of the backends the adapters in this package search.
+const (
+	BackendCodeSearch = "cs"
+	BackendREST       = "rest"
+)
+
+// BackendOf returns the name of the backend s searches, or "" if s does
+// not tell. Results of different backends differ for the same query.
+func BackendOf(s Searcher) string {
+	if b, ok := s.(interface{ Backend() string }); ok {
+		return b.Backend()
+	}
+	return ""
+}